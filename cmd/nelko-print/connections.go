@@ -0,0 +1,468 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2/dialog"
+
+	"nelko-print/internal/printer"
+)
+
+// printerConn is an open connection to one printer
+type printerConn struct {
+	device  savedDevice
+	printer *printer.Printer
+	rfcomm  *printer.RFCOMMConnection
+}
+
+// Close closes the serial port and releases the RFCOMM device, if any
+func (c *printerConn) Close() {
+	if c.printer != nil {
+		c.printer.Close()
+	}
+	if c.rfcomm != nil {
+		c.rfcomm.Close()
+	}
+}
+
+// connection returns the open connection for a device address, or nil
+func (a *App) connection(address string) *printerConn {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	return a.conns[address]
+}
+
+// targetConnection returns the connection print jobs are routed to, or nil
+func (a *App) targetConnection() *printerConn {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	return a.conns[a.target]
+}
+
+// addConnection registers a new connection and makes it the print target
+func (a *App) addConnection(c *printerConn) {
+	a.connMu.Lock()
+	a.conns[c.device.Address] = c
+	a.connMu.Unlock()
+
+	a.saveLastDevice(c.device)
+	a.setTarget(c.device.Address)
+}
+
+// removeConnection closes and forgets the connection for a device address
+func (a *App) removeConnection(address string) {
+	a.connMu.Lock()
+	c := a.conns[address]
+	delete(a.conns, address)
+	if a.target == address {
+		a.target = ""
+	}
+	a.connMu.Unlock()
+
+	if c != nil {
+		c.Close()
+	}
+	a.refreshTargets()
+}
+
+// connectionLabel is the name shown for a connection in the "Print To" list
+func (a *App) connectionLabel(c *printerConn) string {
+	if p := a.profileForDevice(c.device.Address); p != nil {
+		return p.Name
+	}
+	if c.device.Name != "" && c.device.Name != c.device.Address {
+		return fmt.Sprintf("%s (%s)", c.device.Name, c.device.Address)
+	}
+	return c.device.Address
+}
+
+// refreshTargets rebuilds the "Print To" list from the open connections
+func (a *App) refreshTargets() {
+	a.connMu.Lock()
+	addresses := make([]string, 0, len(a.conns))
+	for addr := range a.conns {
+		addresses = append(addresses, addr)
+	}
+	target := a.target
+	a.connMu.Unlock()
+	sort.Strings(addresses)
+
+	options := make([]string, len(addresses))
+	selected := ""
+	for i, addr := range addresses {
+		options[i] = a.connectionLabel(a.connection(addr))
+		if addr == target {
+			selected = options[i]
+		}
+	}
+	a.targetAddresses = addresses
+
+	a.targetSelect.Options = options
+	if selected != "" {
+		a.targetSelect.SetSelected(selected)
+	} else if len(options) > 0 {
+		a.targetSelect.SetSelectedIndex(0)
+	} else {
+		a.targetSelect.ClearSelected()
+	}
+	a.targetSelect.Refresh()
+
+	a.updateConnectButton()
+	a.updatePrintButton()
+}
+
+// setTarget routes print jobs to the printer at address and applies its profile
+func (a *App) setTarget(address string) {
+	a.connMu.Lock()
+	a.target = address
+	a.connMu.Unlock()
+
+	if p := a.profileForDevice(address); p != nil {
+		a.applyProfileSettings(*p)
+	}
+	a.refreshTargets()
+}
+
+// updateConnectButton shows Connect or Disconnect for the selected Bluetooth device
+func (a *App) updateConnectButton() {
+	device := a.getSelectedBluetoothDevice()
+	if device != nil && a.connection(device.MAC) != nil {
+		a.connectBtn.SetText("Disconnect")
+	} else {
+		a.connectBtn.SetText("Connect")
+	}
+}
+
+// updatePrintButton enables printing when there is a target printer and something to print
+func (a *App) updatePrintButton() {
+	if a.targetConnection() != nil && a.sourceImg != nil {
+		a.printBtn.Enable()
+	} else {
+		a.printBtn.Disable()
+	}
+}
+
+func (a *App) refreshBluetoothDevices() {
+	a.statusLabel.SetText("Scanning for paired devices...")
+
+	devices, err := printer.ListPairedBluetoothDevices()
+	if err != nil {
+		a.statusLabel.SetText(fmt.Sprintf("BT scan failed: %v", err))
+		return
+	}
+
+	// Keep the current selection if the device is still paired
+	current := ""
+	if d := a.getSelectedBluetoothDevice(); d != nil {
+		current = d.MAC
+	}
+	a.btDevices = devices
+
+	// Build display list
+	options := make([]string, len(devices))
+	for i, d := range devices {
+		options[i] = fmt.Sprintf("%s (%s)", d.Name, d.MAC)
+	}
+
+	a.btDeviceSelect.Options = options
+	if len(options) > 0 {
+		// Prefer the selected or last used printer, otherwise try to
+		// auto-select a Nelko device
+		if last, ok := a.loadLastDevice(); ok && last.Transport == transportBluetooth && current == "" {
+			current = last.Address
+		}
+		selectedIdx := -1
+		for i, d := range devices {
+			if d.MAC == current {
+				selectedIdx = i
+				break
+			}
+		}
+		if selectedIdx < 0 {
+			selectedIdx = 0
+			for i, d := range devices {
+				if strings.Contains(strings.ToLower(d.Name), "nelko") ||
+					strings.Contains(strings.ToLower(d.Name), "p21") {
+					selectedIdx = i
+					break
+				}
+			}
+		}
+		a.btDeviceSelect.SetSelected(options[selectedIdx])
+	} else {
+		a.btDeviceSelect.ClearSelected()
+	}
+	a.btDeviceSelect.Refresh()
+
+	a.statusLabel.SetText(fmt.Sprintf("Found %d paired device(s)", len(devices)))
+}
+
+func (a *App) refreshPorts() {
+	ports, err := printer.ListPorts()
+	if err != nil {
+		a.statusLabel.SetText(fmt.Sprintf("Port scan failed: %v", err))
+		return
+	}
+
+	// Keep the current selection if the port is still there
+	selected := a.selectedPort()
+	a.ports = ports
+
+	options := make([]string, len(ports))
+	for i, p := range ports {
+		options[i] = p.String()
+	}
+	a.portSelect.Options = options
+
+	if !a.selectPort(selected) {
+		if len(options) > 0 {
+			a.portSelect.SetSelectedIndex(0)
+		} else {
+			a.portSelect.ClearSelected()
+		}
+	}
+	a.portSelect.Refresh()
+}
+
+// selectedPort returns the device path of the selected manual port, or ""
+func (a *App) selectedPort() string {
+	idx := a.portSelect.SelectedIndex()
+	if idx < 0 || idx >= len(a.ports) {
+		return ""
+	}
+	return a.ports[idx].Path
+}
+
+// selectPort selects the manual port with the given path, if it is present
+func (a *App) selectPort(path string) bool {
+	for i, p := range a.ports {
+		if p.Path == path {
+			a.portSelect.SetSelectedIndex(i)
+			return true
+		}
+	}
+	return false
+}
+
+// autoConnectLastDevice reconnects to the last used printer if it is still available
+func (a *App) autoConnectLastDevice() {
+	last, ok := a.loadLastDevice()
+	if !ok || a.connection(last.Address) != nil {
+		return
+	}
+
+	if !a.connectDevice(last) {
+		a.statusLabel.SetText(fmt.Sprintf("Last printer %s not found", last.Name))
+	}
+}
+
+// connectDevice selects a saved device and connects to it
+// It reports false if the device is not paired or its port is not present
+func (a *App) connectDevice(d savedDevice) bool {
+	switch d.Transport {
+	case transportBluetooth:
+		for i, bt := range a.btDevices {
+			if bt.MAC == d.Address {
+				a.btDeviceSelect.SetSelectedIndex(i)
+				a.connectBluetooth()
+				return true
+			}
+		}
+	case transportPort:
+		if a.selectPort(d.Address) {
+			a.connectManualPort()
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) getSelectedBluetoothDevice() *printer.BluetoothDevice {
+	selectedIdx := a.btDeviceSelect.SelectedIndex()
+	if selectedIdx < 0 || selectedIdx >= len(a.btDevices) {
+		return nil
+	}
+	return &a.btDevices[selectedIdx]
+}
+
+func (a *App) connectBluetooth() {
+	device := a.getSelectedBluetoothDevice()
+	if device == nil {
+		dialog.ShowError(fmt.Errorf("no Bluetooth device selected"), a.window)
+		return
+	}
+
+	// If this device is already connected, disconnect it
+	if a.connection(device.MAC) != nil {
+		a.disconnect(device.MAC)
+		return
+	}
+
+	if a.channelErr != nil {
+		dialog.ShowError(a.channelErr, a.window)
+		return
+	}
+
+	// Check for rfcomm
+	if err := printer.CheckRFCOMMInstalled(); err != nil {
+		dialog.ShowError(err, a.window)
+		return
+	}
+
+	// Check for privilege helper
+	helper := printer.CheckPrivilegeHelper()
+	if helper == "" {
+		dialog.ShowError(fmt.Errorf("no privilege helper found (need pkexec or sudo)"), a.window)
+		return
+	}
+
+	// Disable button during connection
+	a.connectBtn.Disable()
+	a.btDeviceSelect.Disable()
+	a.refreshBTBtn.Disable()
+
+	channel := a.rfcommChannel
+	if p := a.profileForDevice(device.MAC); p != nil && p.Device.Channel > 0 {
+		channel = p.Device.Channel
+	}
+
+	go func() {
+		defer func() {
+			a.connectBtn.Enable()
+			a.btDeviceSelect.Enable()
+			a.refreshBTBtn.Enable()
+		}()
+
+		a.statusLabel.SetText(fmt.Sprintf("Connecting to %s...", device.Name))
+
+		// Establish RFCOMM connection
+		conn, err := printer.EstablishRFCOMM(device.MAC, channel, func(status string) {
+			a.statusLabel.SetText(status)
+		})
+
+		if err != nil {
+			a.statusLabel.SetText(fmt.Sprintf("Connection failed: %v", err))
+
+			// Show error dialog
+			dialog.ShowError(fmt.Errorf("failed to connect: %v", err), a.window)
+			return
+		}
+
+		// Now connect to the serial port
+		p, err := printer.Connect(conn.DevicePath)
+		if err != nil {
+			conn.Close()
+			a.statusLabel.SetText(fmt.Sprintf("Serial connect failed: %v", err))
+			dialog.ShowError(err, a.window)
+			return
+		}
+		p.SetModel(a.model)
+
+		a.addConnection(&printerConn{
+			device: savedDevice{
+				Transport: transportBluetooth,
+				Address:   device.MAC,
+				Name:      device.Name,
+				Channel:   channel,
+			},
+			printer: p,
+			rfcomm:  conn,
+		})
+		a.statusLabel.SetText(fmt.Sprintf("Connected to %s via %s", device.Name, conn.DevicePath))
+
+		// Try to get battery
+		if batt, err := p.GetBattery(); err == nil {
+			a.statusLabel.SetText(fmt.Sprintf("Connected to %s (Battery: %d%%)", device.Name, batt))
+		}
+
+		// Refresh ports list to show the new device
+		a.refreshPorts()
+	}()
+}
+
+func (a *App) connectManualPort() {
+	port := a.selectedPort()
+	if port == "" {
+		dialog.ShowError(fmt.Errorf("no port selected"), a.window)
+		return
+	}
+
+	// If this port is already connected, disconnect it
+	if a.connection(port) != nil {
+		a.disconnect(port)
+		return
+	}
+
+	p, err := printer.Connect(port)
+	if err != nil {
+		dialog.ShowError(err, a.window)
+		return
+	}
+	p.SetModel(a.model)
+
+	a.addConnection(&printerConn{
+		device: savedDevice{
+			Transport: transportPort,
+			Address:   port,
+			Name:      port,
+		},
+		printer: p,
+	})
+	a.statusLabel.SetText(fmt.Sprintf("Connected to %s", port))
+
+	// Try to get battery
+	if batt, err := p.GetBattery(); err == nil {
+		a.statusLabel.SetText(fmt.Sprintf("Connected to %s (Battery: %d%%)", port, batt))
+	}
+}
+
+// disconnect closes the connection to the printer at address
+func (a *App) disconnect(address string) {
+	a.removeConnection(address)
+	a.statusLabel.SetText(fmt.Sprintf("Disconnected %s", address))
+}
+
+// disconnectAll closes every open printer connection
+func (a *App) disconnectAll() {
+	a.connMu.Lock()
+	conns := a.conns
+	a.conns = make(map[string]*printerConn)
+	a.connMu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// watchDevices keeps the port and Bluetooth device lists current and drops
+// connections whose device node disappears (e.g. the printer was switched off)
+func (a *App) watchDevices(w printer.DeviceWatcher) {
+	for ev := range w.Events() {
+		a.refreshPorts()
+		a.refreshBluetoothDevices()
+
+		if ev.Type != printer.DeviceRemoved {
+			continue
+		}
+		if c := a.connectionForPath(ev.Path); c != nil {
+			name := a.connectionLabel(c)
+			a.removeConnection(c.device.Address)
+			a.statusLabel.SetText(fmt.Sprintf("%s disconnected: %s was removed", name, ev.Path))
+		}
+	}
+}
+
+// connectionForPath returns the connection using the given device path, or nil
+func (a *App) connectionForPath(path string) *printerConn {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+
+	for _, c := range a.conns {
+		if c.device.Address == path || (c.rfcomm != nil && c.rfcomm.DevicePath == path) {
+			return c
+		}
+	}
+	return nil
+}
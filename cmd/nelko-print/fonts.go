@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"nelko-print/internal/imaging"
+)
+
+// defaultFontFamily is the family of the embedded font
+const defaultFontFamily = "Go"
+
+// hyphenOff is the hyphenation choice for no hyphenation
+const hyphenOff = "Off"
+
+// buildFontPicker creates the font family and style selects for the Text tab
+func (a *App) buildFontPicker() fyne.CanvasObject {
+	a.fontStyleSelect = widget.NewSelect(nil, func(s string) {
+		a.fontStyle = s
+		a.fyneApp.Preferences().SetString(prefFontStyle, s)
+		a.updateTextPreview()
+	})
+
+	a.fontFamilySelect = widget.NewSelect(imaging.Fonts.Families(), func(s string) {
+		a.fontFamily = s
+		a.fyneApp.Preferences().SetString(prefFontFamily, s)
+		a.refreshFontStyles()
+	})
+	a.fontFamilySelect.SetSelected(a.fontFamily)
+
+	return container.NewGridWithColumns(2, a.fontFamilySelect, a.fontStyleSelect)
+}
+
+// refreshFontStyles lists the styles of the selected family
+func (a *App) refreshFontStyles() {
+	styles := imaging.Fonts.Styles(a.fontFamily)
+	a.fontStyleSelect.Options = styles
+
+	// Keep the style if the family has it, otherwise use its regular style
+	selected := ""
+	if name, ok := imaging.Fonts.Lookup(a.fontFamily, a.fontStyle); ok {
+		if info, ok := imaging.Fonts.Info(name); ok {
+			selected = info.Style
+		}
+	}
+	if selected != "" {
+		a.fontStyleSelect.SetSelected(selected)
+	} else {
+		a.fontStyleSelect.ClearSelected()
+	}
+	a.fontStyleSelect.Refresh()
+}
+
+// loadFonts registers the user and system fonts and adds them to the picker
+// Scanning reads every font file, so it runs in the background
+func (a *App) loadFonts() {
+	n := imaging.Fonts.ScanDirs(imaging.FontDirs()...)
+
+	a.fontFamilySelect.Options = imaging.Fonts.Families()
+	a.fontFamilySelect.Refresh()
+
+	// The saved font may only be known now
+	if a.fontFamilySelect.Selected != a.fontFamily {
+		a.fontFamilySelect.SetSelected(a.fontFamily)
+	} else {
+		a.refreshFontStyles()
+	}
+
+	if n > 0 {
+		a.statusLabel.SetText(fmt.Sprintf("Loaded %d font(s)", n))
+	}
+}
+
+// loadHyphenation finds the installed hyphenation dictionaries and offers
+// their languages
+func (a *App) loadHyphenation() {
+	imaging.Hyphenation.ScanDirs(imaging.HyphenDirs()...)
+	a.hyphenSelect.Options = append([]string{hyphenOff}, imaging.Hyphenation.Languages()...)
+	a.hyphenSelect.Refresh()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"nelko-print/internal/tspl"
+)
+
+// loadCustomSizes reads user-defined label sizes, keyed by model name
+func (a *App) loadCustomSizes() map[string][]tspl.LabelSize {
+	sizes := make(map[string][]tspl.LabelSize)
+	raw := a.fyneApp.Preferences().String(prefCustomSizes)
+	if raw == "" {
+		return sizes
+	}
+	if err := json.Unmarshal([]byte(raw), &sizes); err != nil {
+		return make(map[string][]tspl.LabelSize)
+	}
+	return sizes
+}
+
+// saveCustomSizes writes the user-defined label sizes to preferences
+func (a *App) saveCustomSizes() {
+	data, err := json.Marshal(a.customSizes)
+	if err != nil {
+		return
+	}
+	a.fyneApp.Preferences().SetString(prefCustomSizes, string(data))
+}
+
+// sizesForModel returns the model's built-in sizes followed by the user's custom sizes
+func (a *App) sizesForModel(m tspl.Model) []tspl.LabelSize {
+	sizes := append([]tspl.LabelSize{}, m.Sizes...)
+	return append(sizes, a.customSizes[m.Name]...)
+}
+
+// showCustomSizeDialog adds a user-defined label size for the current model
+func (a *App) showCustomSizeDialog() {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("optional, e.g. 14x30mm")
+	widthEntry := widget.NewEntry()
+	widthEntry.SetPlaceHolder("mm")
+	lengthEntry := widget.NewEntry()
+	lengthEntry.SetPlaceHolder("mm")
+	gapEntry := widget.NewEntry()
+	gapEntry.SetPlaceHolder(fmt.Sprintf("default (%g)", a.model.DefaultGap))
+	offsetEntry := widget.NewEntry()
+	offsetEntry.SetText("0")
+	radiusEntry := widget.NewEntry()
+	radiusEntry.SetText("0")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Width (mm)", widthEntry),
+		widget.NewFormItem("Length (mm)", lengthEntry),
+		widget.NewFormItem("Gap (mm)", gapEntry),
+		widget.NewFormItem("Gap Offset (mm)", offsetEntry),
+		widget.NewFormItem("Corner Radius (mm)", radiusEntry),
+	}
+
+	title := fmt.Sprintf("Custom Label Size (%s)", a.model.Name)
+	dialog.ShowForm(title, "Add", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		spec := tspl.LabelSize{Name: nameEntry.Text}
+		fields := []struct {
+			entry *widget.Entry
+			name  string
+			value *float64
+		}{
+			{widthEntry, "width", &spec.Width},
+			{lengthEntry, "length", &spec.Height},
+			{offsetEntry, "gap offset", &spec.Offset},
+			{radiusEntry, "corner radius", &spec.CornerRadius},
+		}
+		for _, f := range fields {
+			v, err := parseMM(f.entry.Text, f.name)
+			if err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			*f.value = v
+		}
+
+		// An empty gap uses the model's default; 0 is continuous stock
+		if gapEntry.Text != "" {
+			gap, err := parseMM(gapEntry.Text, "gap")
+			if err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			spec.Gap = tspl.MM(gap)
+		}
+
+		size, err := a.model.CustomLabelSize(spec)
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+
+		for _, builtin := range a.model.Sizes {
+			if builtin.Name == size.Name {
+				dialog.ShowError(fmt.Errorf("%q is a built-in label size", size.Name), a.window)
+				return
+			}
+		}
+
+		// Replace a custom size with the same name
+		custom := a.customSizes[a.model.Name]
+		replaced := false
+		for i := range custom {
+			if custom[i].Name == size.Name {
+				custom[i] = size
+				replaced = true
+			}
+		}
+		if !replaced {
+			custom = append(custom, size)
+		}
+		a.customSizes[a.model.Name] = custom
+		a.saveCustomSizes()
+
+		a.labelSize = size
+		a.setModel(a.model)
+	}, a.window)
+}
+
+// deleteCustomSize removes the selected label size if it is user-defined
+func (a *App) deleteCustomSize() {
+	if !a.labelSize.Custom {
+		dialog.ShowInformation("Delete Label Size", "Only custom label sizes can be deleted.", a.window)
+		return
+	}
+
+	name := a.labelSize.Name
+	dialog.ShowConfirm("Delete Label Size", fmt.Sprintf("Delete custom label size %q?", name), func(ok bool) {
+		if !ok {
+			return
+		}
+		custom := a.customSizes[a.model.Name]
+		for i, s := range custom {
+			if s.Name == name {
+				a.customSizes[a.model.Name] = append(custom[:i], custom[i+1:]...)
+				break
+			}
+		}
+		a.saveCustomSizes()
+		a.setModel(a.model)
+	}, a.window)
+}
+
+// parseMM parses a length in mm from a form field
+func parseMM(s, field string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", field, s)
+	}
+	return v, nil
+}
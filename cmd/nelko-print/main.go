@@ -1,11 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
+	"image/color"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
+	"sync"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -23,18 +26,32 @@ import (
 const (
 	AppVersion = "1.2.0"
 	AppName    = "Nelko P21 Print"
+	AppID      = "io.github.tylercode.nelko-print"
 )
 
 type App struct {
 	fyneApp    fyne.App
 	window     fyne.Window
-	printer    *printer.Printer
-	rfcommConn *printer.RFCOMMConnection
 	sourceImg  image.Image
 	previewImg *canvas.Image
 
+	// Open printer connections keyed by device address, and the one jobs go to
+	conns           map[string]*printerConn
+	connMu          sync.Mutex
+	target          string
+	targetAddresses []string
+
+	// Named printer profiles and user-defined label sizes per model
+	profiles    []printerProfile
+	customSizes map[string][]tspl.LabelSize
+
+	// Serial/RFCOMM hotplug events
+	watcher printer.DeviceWatcher
+
 	// Settings
+	model     tspl.Model
 	labelSize tspl.LabelSize
+	media     tspl.Media
 	density   int
 	threshold uint8
 	copies    int
@@ -47,9 +64,18 @@ type App struct {
 	btDeviceSelect *widget.Select
 	portSelect     *widget.Select
 	refreshBTBtn   *widget.Button
-
-	// Bluetooth devices cache
-	btDevices []printer.BluetoothDevice
+	targetSelect   *widget.Select
+	profileSelect  *widget.Select
+	modelSelect    *widget.Select
+	sizeSelect     *widget.Select
+	densitySlider  *widget.Slider
+	mediaForm      *mediaForm
+
+	// Bluetooth devices and serial ports cache
+	btDevices     []printer.BluetoothDevice
+	ports         []printer.PortInfo
+	rfcommChannel int
+	channelErr    error // why the typed RFCOMM channel is not valid
 
 	// Text mode
 	textEntry     *widget.Entry
@@ -57,16 +83,72 @@ type App struct {
 	fontSize      float64
 	textInvert    bool
 	wordBreakOnly bool
+	textMarkup    bool
+	hyphenate     string // hyphenation language, "" for none
+	balanced      bool
+	hyphenSelect  *widget.Select
+
+	// Text font, picked from the embedded, user and system fonts
+	fontFamily       string
+	fontStyle        string
+	textBold         bool
+	textItalic       bool
+	textCondensed    bool
+	fontFamilySelect *widget.Select
+	fontStyleSelect  *widget.Select
+
+	// Auto-fit picks the font size; the label shows the size in use
+	fit           bool
+	fitShrinkOnly bool
+	fitMin        float64
+	fitMax        float64
+	fontSizeLabel *widget.Label
+
+	// Text layout; marginsMM is top, right, bottom, left, nil for the default
+	textAlign     imaging.HAlign
+	textVAlign    imaging.VAlign
+	marginsMM     []float64
+	lineSpacing   float64
+	letterSpacing float64
+
+	// Text effects; the box radius is in mm
+	textUnderline bool
+	textStrike    bool
+	textHighlight bool
+	textOutline   int
+	textBox       bool
+	boxRadiusMM   float64
+
+	// Banner mode splits long text across several labels
+	banner          bool
+	bannerOverlapMM float64
+	bannerOpts      imaging.BannerOptions
+	bannerPages     []image.Image
+
+	// Rotation and mirroring of the image or text before it is printed; the
+	// angle is the quarter turn plus the fine angle, clockwise in degrees
+	// Text turns by quarters with its orientation instead, so that it is
+	// fitted to the turned label
+	quarterTurn float64
+	fineAngle   float64
+	mirrorH     bool
+	mirrorV     bool
+	textSource  bool // sourceImg is rendered text rather than a loaded image
 }
 
 func main() {
-	a := app.New()
+	printerName := flag.String("printer", "", "name of the printer profile to connect to and print with")
+	flag.Parse()
+
+	a := app.NewWithID(AppID)
 	w := a.NewWindow(fmt.Sprintf("%s v%s", AppName, AppVersion))
 	w.Resize(fyne.NewSize(650, 550))
 
 	nelkoApp := &App{
 		fyneApp:       a,
 		window:        w,
+		conns:         make(map[string]*printerConn),
+		model:         tspl.ModelP21,
 		labelSize:     tspl.Label14x40,
 		density:       10,
 		threshold:     128,
@@ -76,7 +158,16 @@ func main() {
 		orientation:   imaging.Horizontal,
 		textInvert:    false,
 		wordBreakOnly: false,
+		rfcommChannel: defaultRFCOMMChannel,
 	}
+	if last, ok := nelkoApp.loadLastDevice(); ok && last.Transport == transportBluetooth {
+		nelkoApp.rfcommChannel = last.Channel
+	}
+	nelkoApp.profiles = nelkoApp.loadProfiles()
+	nelkoApp.customSizes = nelkoApp.loadCustomSizes()
+	nelkoApp.media = nelkoApp.loadMedia()
+	nelkoApp.fontFamily = a.Preferences().StringWithFallback(prefFontFamily, defaultFontFamily)
+	nelkoApp.fontStyle = a.Preferences().StringWithFallback(prefFontStyle, "Regular")
 
 	// Set up menu
 	w.SetMainMenu(nelkoApp.buildMenu())
@@ -84,6 +175,29 @@ func main() {
 	w.SetOnClosed(func() {
 		nelkoApp.cleanup()
 	})
+
+	// Update port lists live as devices come and go
+	if watcher, err := printer.NewDeviceWatcher(); err == nil {
+		nelkoApp.watcher = watcher
+		go nelkoApp.watchDevices(watcher)
+	}
+
+	go nelkoApp.loadFonts()
+	go nelkoApp.loadHyphenation()
+	go imaging.Icons.LoadDir(imaging.UserIconDir())
+
+	// Refresh BT devices on startup, then connect to the printer named with
+	// --printer, or reconnect to the last printer if enabled
+	go func() {
+		nelkoApp.refreshBluetoothDevices()
+		switch {
+		case *printerName != "":
+			nelkoApp.connectProfile(*printerName)
+		case nelkoApp.autoConnectEnabled():
+			nelkoApp.autoConnectLastDevice()
+		}
+	}()
+
 	w.ShowAndRun()
 }
 
@@ -95,7 +209,7 @@ func (a *App) buildMenu() *fyne.MainMenu {
 
 	helpMenu := fyne.NewMenu("Help", aboutItem)
 
-	return fyne.NewMainMenu(helpMenu)
+	return fyne.NewMainMenu(a.buildPrinterMenu(), helpMenu)
 }
 
 func (a *App) showAboutDialog() {
@@ -120,12 +234,10 @@ func parseURL(urlStr string) *url.URL {
 }
 
 func (a *App) cleanup() {
-	if a.printer != nil {
-		a.printer.Close()
-	}
-	if a.rfcommConn != nil {
-		a.rfcommConn.Close()
+	if a.watcher != nil {
+		a.watcher.Close()
 	}
+	a.disconnectAll()
 }
 
 func (a *App) buildUI() fyne.CanvasObject {
@@ -134,7 +246,9 @@ func (a *App) buildUI() fyne.CanvasObject {
 
 	// === BLUETOOTH CONNECTION SECTION ===
 	btLabel := widget.NewLabel("Bluetooth Printer:")
-	a.btDeviceSelect = widget.NewSelect([]string{}, func(s string) {})
+	a.btDeviceSelect = widget.NewSelect([]string{}, func(s string) {
+		a.updateConnectButton()
+	})
 	a.refreshBTBtn = widget.NewButton("↻", func() {
 		a.refreshBluetoothDevices()
 	})
@@ -143,9 +257,6 @@ func (a *App) buildUI() fyne.CanvasObject {
 		a.connectBluetooth()
 	})
 
-	// Refresh BT devices on startup
-	go a.refreshBluetoothDevices()
-
 	btRow := container.NewBorder(
 		nil, nil, nil,
 		container.NewHBox(a.refreshBTBtn, a.connectBtn),
@@ -170,31 +281,115 @@ func (a *App) buildUI() fyne.CanvasObject {
 		a.portSelect,
 	)
 
+	channelEntry := widget.NewEntry()
+	channelEntry.SetText(fmt.Sprintf("%d", a.rfcommChannel))
+	channelEntry.Validator = func(s string) error {
+		_, err := parseRFCOMMChannel(s)
+		return err
+	}
+	channelEntry.OnChanged = func(s string) {
+		n, err := parseRFCOMMChannel(s)
+		a.channelErr = err
+		if err != nil {
+			a.statusLabel.SetText(err.Error())
+			return
+		}
+		a.rfcommChannel = n
+	}
+
+	autoConnectCheck := widget.NewCheck("Connect to last printer on startup", nil)
+	autoConnectCheck.SetChecked(a.autoConnectEnabled())
+	autoConnectCheck.OnChanged = func(b bool) {
+		a.setAutoConnect(b)
+	}
+
+	compressCheck := widget.NewCheck("Compress bitmaps (faster, if the printer accepts it)", nil)
+	compressCheck.SetChecked(a.compressEnabled())
+	compressCheck.OnChanged = func(b bool) {
+		a.setCompress(b)
+	}
+
 	// Advanced section (manual port)
 	advancedContent := container.NewVBox(
 		widget.NewLabel("Manual Port (if already connected):"),
 		manualRow,
+		widget.NewForm(
+			widget.NewFormItem("RFCOMM Channel", channelEntry),
+		),
+		autoConnectCheck,
+		compressCheck,
 	)
 
+	// === PRINTER PROFILES ===
+	a.profileSelect = widget.NewSelect([]string{}, func(s string) {
+		a.selectProfile(s)
+	})
+	a.profileSelect.PlaceHolder = "(no profile)"
+	a.refreshProfiles()
+
+	saveProfileBtn := widget.NewButton("Save", func() {
+		a.showSaveProfileDialog()
+	})
+	deleteProfileBtn := widget.NewButton("Delete", func() {
+		a.deleteSelectedProfile()
+	})
+
+	profileRow := container.NewBorder(
+		nil, nil, nil,
+		container.NewHBox(saveProfileBtn, deleteProfileBtn),
+		a.profileSelect,
+	)
+
+	// Connected printer that print jobs are routed to
+	a.targetSelect = widget.NewSelect([]string{}, func(s string) {
+		idx := a.targetSelect.SelectedIndex()
+		if idx < 0 || idx >= len(a.targetAddresses) || a.targetAddresses[idx] == a.target {
+			return
+		}
+		a.setTarget(a.targetAddresses[idx])
+	})
+	a.targetSelect.PlaceHolder = "(not connected)"
+
 	// Print settings
-	sizeOptions := make([]string, len(tspl.AllSizes))
-	for i, s := range tspl.AllSizes {
-		sizeOptions[i] = s.Name
-	}
-	sizeSelect := widget.NewSelect(sizeOptions, func(s string) {
-		for _, size := range tspl.AllSizes {
+	a.sizeSelect = widget.NewSelect([]string{}, func(s string) {
+		for _, size := range a.sizesForModel(a.model) {
 			if size.Name == s {
 				a.labelSize = size
-				a.updatePreview()
+				if len(a.bannerPages) > 0 {
+					// Banner pages are cut to the label length
+					a.updateTextPreview()
+				} else {
+					a.updatePreview()
+				}
 				break
 			}
 		}
 	})
-	sizeSelect.SetSelected(a.labelSize.Name)
 
-	densitySlider := widget.NewSlider(0, 15)
-	densitySlider.Value = float64(a.density)
-	densitySlider.OnChanged = func(f float64) {
+	modelOptions := make([]string, len(tspl.AllModels))
+	for i, m := range tspl.AllModels {
+		modelOptions[i] = m.Name
+	}
+	a.modelSelect = widget.NewSelect(modelOptions, func(s string) {
+		a.setModel(tspl.ModelByName(s))
+	})
+	a.modelSelect.SetSelected(a.model.Name)
+
+	customSizeBtn := widget.NewButton("+", func() {
+		a.showCustomSizeDialog()
+	})
+	deleteSizeBtn := widget.NewButton("−", func() {
+		a.deleteCustomSize()
+	})
+	sizeRow := container.NewBorder(
+		nil, nil, nil,
+		container.NewHBox(customSizeBtn, deleteSizeBtn),
+		a.sizeSelect,
+	)
+
+	a.densitySlider = widget.NewSlider(0, 15)
+	a.densitySlider.Value = float64(a.density)
+	a.densitySlider.OnChanged = func(f float64) {
 		a.density = int(f)
 	}
 
@@ -250,19 +445,23 @@ func (a *App) buildUI() fyne.CanvasObject {
 		a.updateTextPreview()
 	}
 
-	orientationSelect := widget.NewSelect([]string{"Horizontal", "Vertical"}, func(s string) {
-		if s == "Vertical" {
-			a.orientation = imaging.Vertical
-		} else {
-			a.orientation = imaging.Horizontal
-		}
+	orientations := map[string]imaging.Orientation{
+		"Horizontal":     imaging.Horizontal,
+		"Vertical":       imaging.Vertical,
+		"Upside down":    imaging.UpsideDown,
+		"Vertical (CCW)": imaging.VerticalCCW,
+	}
+	orientationSelect := widget.NewSelect([]string{"Horizontal", "Vertical", "Upside down", "Vertical (CCW)"}, func(s string) {
+		a.orientation = orientations[s]
 		a.updateTextPreview()
 	})
 	orientationSelect.SetSelected("Horizontal")
 
 	fontSizeSlider := widget.NewSlider(4, 72) // Reduced min from 8 to 4
 	fontSizeSlider.Value = a.fontSize
+	a.fontSizeLabel = widget.NewLabel(fmt.Sprintf("%g pt", a.fontSize))
 	fontSizeSlider.OnChanged = func(f float64) {
+		a.fontSizeLabel.SetText(fmt.Sprintf("%g pt", f))
 		a.fontSize = f
 		a.updateTextPreview()
 	}
@@ -272,16 +471,245 @@ func (a *App) buildUI() fyne.CanvasObject {
 		a.updateTextPreview()
 	})
 
-	wordBreakCheck := widget.NewCheck("Break on space only", func(b bool) {
+	wordBreakCheck := widget.NewCheck("Break between words", func(b bool) {
 		a.wordBreakOnly = b
 		a.updateTextPreview()
 	})
 
+	// Languages are added once the dictionaries have been found
+	a.hyphenSelect = widget.NewSelect([]string{hyphenOff}, func(s string) {
+		a.hyphenate = ""
+		if s != hyphenOff {
+			a.hyphenate = s
+		}
+		a.updateTextPreview()
+	})
+	a.hyphenSelect.SetSelected(hyphenOff)
+
+	balancedCheck := widget.NewCheck("Even line lengths", func(b bool) {
+		a.balanced = b
+		a.updateTextPreview()
+	})
+
+	markupCheck := widget.NewCheck("Markup (**bold**, *italic*, __underline__, ~~strike~~, :icon:)", func(b bool) {
+		a.textMarkup = b
+		a.updateTextPreview()
+	})
+
+	bannerCheck := widget.NewCheck("Banner (split across labels)", func(b bool) {
+		a.banner = b
+		a.updateTextPreview()
+	})
+
+	overlapEntry := widget.NewEntry()
+	overlapEntry.SetPlaceHolder("0")
+	overlapEntry.OnChanged = func(s string) {
+		mm, err := parseMM(s, "overlap")
+		if err != nil || mm < 0 {
+			return
+		}
+		a.bannerOverlapMM = mm
+		a.updateTextPreview()
+	}
+
+	joinMarksCheck := widget.NewCheck("Join marks", func(b bool) {
+		a.bannerOpts.JoinMarks = b
+		a.updateTextPreview()
+	})
+
+	pageNumbersCheck := widget.NewCheck("Page numbers", func(b bool) {
+		a.bannerOpts.PageNumbers = b
+		a.updateTextPreview()
+	})
+
+	boldCheck := widget.NewCheck("Bold", func(b bool) {
+		a.textBold = b
+		a.updateTextPreview()
+	})
+
+	italicCheck := widget.NewCheck("Italic", func(b bool) {
+		a.textItalic = b
+		a.updateTextPreview()
+	})
+
+	condensedCheck := widget.NewCheck("Condensed", func(b bool) {
+		a.textCondensed = b
+		a.updateTextPreview()
+	})
+
+	fitMinEntry := widget.NewEntry()
+	fitMinEntry.SetPlaceHolder(fmt.Sprintf("%g", imaging.DefaultMinFontSize))
+	fitMinEntry.OnChanged = func(s string) {
+		if v, err := strconv.ParseFloat(s, 64); err == nil && v > 0 {
+			a.fitMin = v
+		} else {
+			a.fitMin = 0
+		}
+		a.updateTextPreview()
+	}
+
+	fitMaxEntry := widget.NewEntry()
+	fitMaxEntry.SetPlaceHolder(fmt.Sprintf("%g", imaging.DefaultMaxFontSize))
+	fitMaxEntry.OnChanged = func(s string) {
+		if v, err := strconv.ParseFloat(s, 64); err == nil && v > 0 {
+			a.fitMax = v
+		} else {
+			a.fitMax = 0
+		}
+		a.updateTextPreview()
+	}
+
+	shrinkOnlyCheck := widget.NewCheck("Shrink only", func(b bool) {
+		a.fitShrinkOnly = b
+		a.updateTextPreview()
+	})
+
+	fitCheck := widget.NewCheck("Auto-fit to label", func(b bool) {
+		a.fit = b
+		a.updateTextPreview()
+	})
+
+	alignSelect := widget.NewSelect([]string{"Left", "Center", "Right", "Justify"}, func(s string) {
+		switch s {
+		case "Left":
+			a.textAlign = imaging.AlignLeft
+		case "Right":
+			a.textAlign = imaging.AlignRight
+		case "Justify":
+			a.textAlign = imaging.AlignJustify
+		default:
+			a.textAlign = imaging.AlignCenter
+		}
+		a.updateTextPreview()
+	})
+	alignSelect.SetSelected("Center")
+
+	vAlignSelect := widget.NewSelect([]string{"Top", "Middle", "Bottom"}, func(s string) {
+		switch s {
+		case "Top":
+			a.textVAlign = imaging.VAlignTop
+		case "Bottom":
+			a.textVAlign = imaging.VAlignBottom
+		default:
+			a.textVAlign = imaging.VAlignMiddle
+		}
+		a.updateTextPreview()
+	})
+	vAlignSelect.SetSelected("Middle")
+
+	// Margins stay at the default until one of them is filled in
+	marginEntries := make([]*widget.Entry, 4)
+	marginFields := make([]fyne.CanvasObject, len(marginEntries))
+	onMarginChanged := func(string) {
+		var mm []float64
+		for _, e := range marginEntries {
+			if e.Text != "" {
+				mm = make([]float64, len(marginEntries))
+				break
+			}
+		}
+		for i, e := range marginEntries {
+			if mm == nil || e.Text == "" {
+				continue
+			}
+			v, err := parseMM(e.Text, "margin")
+			if err != nil || v < 0 {
+				return
+			}
+			mm[i] = v
+		}
+		a.marginsMM = mm
+		a.updateTextPreview()
+	}
+	for i, name := range []string{"top", "right", "bottom", "left"} {
+		marginEntries[i] = widget.NewEntry()
+		marginEntries[i].SetPlaceHolder(name)
+		marginEntries[i].OnChanged = onMarginChanged
+		marginFields[i] = marginEntries[i]
+	}
+
+	lineSpacingLabel := widget.NewLabel("1.0x")
+	lineSpacingSlider := widget.NewSlider(0.5, 3)
+	lineSpacingSlider.Step = 0.1
+	lineSpacingSlider.Value = 1
+	lineSpacingSlider.OnChanged = func(f float64) {
+		lineSpacingLabel.SetText(fmt.Sprintf("%.1fx", f))
+		a.lineSpacing = f
+		a.updateTextPreview()
+	}
+
+	letterSpacingLabel := widget.NewLabel("0 dots")
+	letterSpacingSlider := widget.NewSlider(-5, 20)
+	letterSpacingSlider.OnChanged = func(f float64) {
+		letterSpacingLabel.SetText(fmt.Sprintf("%g dots", f))
+		a.letterSpacing = f
+		a.updateTextPreview()
+	}
+
+	underlineCheck := widget.NewCheck("Underline", func(b bool) {
+		a.textUnderline = b
+		a.updateTextPreview()
+	})
+
+	strikeCheck := widget.NewCheck("Strike", func(b bool) {
+		a.textStrike = b
+		a.updateTextPreview()
+	})
+
+	highlightCheck := widget.NewCheck("Highlight lines", func(b bool) {
+		a.textHighlight = b
+		a.updateTextPreview()
+	})
+
+	outlineLabel := widget.NewLabel("Off")
+	outlineSlider := widget.NewSlider(0, 6)
+	outlineSlider.OnChanged = func(f float64) {
+		a.textOutline = int(f)
+		if a.textOutline == 0 {
+			outlineLabel.SetText("Off")
+		} else {
+			outlineLabel.SetText(fmt.Sprintf("%d dots", a.textOutline))
+		}
+		a.updateTextPreview()
+	}
+
+	boxCheck := widget.NewCheck("Box", func(b bool) {
+		a.textBox = b
+		a.updateTextPreview()
+	})
+
+	boxRadiusEntry := widget.NewEntry()
+	boxRadiusEntry.SetPlaceHolder("corner radius (mm)")
+	boxRadiusEntry.OnChanged = func(s string) {
+		mm, err := parseMM(s, "corner radius")
+		if err != nil || mm < 0 {
+			mm = 0
+		}
+		a.boxRadiusMM = mm
+		a.updateTextPreview()
+	}
+
 	textSettings := widget.NewForm(
 		widget.NewFormItem("Orientation", orientationSelect),
-		widget.NewFormItem("Font Size", fontSizeSlider),
+		widget.NewFormItem("Font", a.buildFontPicker()),
+		widget.NewFormItem("", container.NewHBox(boldCheck, italicCheck, condensedCheck)),
+		widget.NewFormItem("Font Size", container.NewBorder(nil, nil, nil, a.fontSizeLabel, fontSizeSlider)),
+		widget.NewFormItem("", container.NewHBox(fitCheck, shrinkOnlyCheck)),
+		widget.NewFormItem("Fit Min/Max (pt)", container.NewGridWithColumns(2, fitMinEntry, fitMaxEntry)),
+		widget.NewFormItem("Align", container.NewGridWithColumns(2, alignSelect, vAlignSelect)),
+		widget.NewFormItem("Margins (mm)", container.NewGridWithColumns(4, marginFields...)),
+		widget.NewFormItem("Line Spacing", container.NewBorder(nil, nil, nil, lineSpacingLabel, lineSpacingSlider)),
+		widget.NewFormItem("Letter Spacing", container.NewBorder(nil, nil, nil, letterSpacingLabel, letterSpacingSlider)),
+		widget.NewFormItem("Effects", container.NewHBox(underlineCheck, strikeCheck, highlightCheck)),
+		widget.NewFormItem("Outline", container.NewBorder(nil, nil, nil, outlineLabel, outlineSlider)),
+		widget.NewFormItem("", container.NewBorder(nil, nil, boxCheck, nil, boxRadiusEntry)),
 		widget.NewFormItem("", textInvertCheck),
-		widget.NewFormItem("", wordBreakCheck),
+		widget.NewFormItem("", container.NewHBox(wordBreakCheck, balancedCheck)),
+		widget.NewFormItem("Hyphenation", a.hyphenSelect),
+		widget.NewFormItem("", markupCheck),
+		widget.NewFormItem("", bannerCheck),
+		widget.NewFormItem("Overlap (mm)", overlapEntry),
+		widget.NewFormItem("", container.NewHBox(joinMarksCheck, pageNumbersCheck)),
 	)
 
 	textTab := container.NewVBox(
@@ -307,26 +735,73 @@ func (a *App) buildUI() fyne.CanvasObject {
 		widget.NewSeparator(),
 		widget.NewAccordion(
 			widget.NewAccordionItem("Advanced", advancedContent),
+			widget.NewAccordionItem("Media", a.buildMediaSection()),
 		),
 		widget.NewSeparator(),
+		widget.NewLabel("Profile"),
+		profileRow,
+		widget.NewLabel("Print To"),
+		a.targetSelect,
+		widget.NewSeparator(),
+		widget.NewLabel("Printer Model"),
+		a.modelSelect,
 		widget.NewLabel("Label Size"),
-		sizeSelect,
+		sizeRow,
 		widget.NewLabel("Density"),
-		densitySlider,
+		a.densitySlider,
 		widget.NewLabel("Copies"),
 		copiesEntry,
 		widget.NewSeparator(),
 		a.printBtn,
 	)
 
+	// Rotation and mirroring apply to both tabs, but the Text tab has its
+	// own orientation for quarter turns
+	rotationSelect := widget.NewSelect([]string{"0°", "90°", "180°", "270°"}, func(s string) {
+		fmt.Sscanf(s, "%g", &a.quarterTurn)
+		a.updatePreview()
+	})
+	rotationSelect.SetSelected("0°")
+	tabs.OnSelected = func(tab *container.TabItem) {
+		if tab.Text == "Text" {
+			rotationSelect.Hide()
+		} else {
+			rotationSelect.Show()
+		}
+	}
+
+	fineAngleEntry := widget.NewEntry()
+	fineAngleEntry.SetPlaceHolder("fine angle (°)")
+	fineAngleEntry.OnChanged = func(s string) {
+		a.fineAngle = 0
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			a.fineAngle = v
+		}
+		a.updatePreview()
+	}
+
+	mirrorHCheck := widget.NewCheck("Mirror ↔", func(b bool) {
+		a.mirrorH = b
+		a.updatePreview()
+	})
+	mirrorVCheck := widget.NewCheck("Mirror ↕", func(b bool) {
+		a.mirrorV = b
+		a.updatePreview()
+	})
+
+	transformSettings := widget.NewForm(
+		widget.NewFormItem("Rotate", container.NewGridWithColumns(2, rotationSelect, fineAngleEntry)),
+		widget.NewFormItem("", container.NewHBox(mirrorHCheck, mirrorVCheck)),
+	)
+
 	// Right panel
 	rightPanel := container.NewBorder(
-		tabs,
+		container.NewVBox(tabs, transformSettings),
 		nil, nil, nil,
 		container.NewCenter(a.previewImg),
 	)
 
-	content := container.NewHSplit(leftPanel, rightPanel)
+	content := container.NewHSplit(container.NewVScroll(leftPanel), rightPanel)
 	content.SetOffset(0.38)
 
 	return container.NewBorder(
@@ -337,210 +812,6 @@ func (a *App) buildUI() fyne.CanvasObject {
 	)
 }
 
-func (a *App) refreshBluetoothDevices() {
-	a.statusLabel.SetText("Scanning for paired devices...")
-
-	devices, err := printer.ListPairedBluetoothDevices()
-	if err != nil {
-		a.statusLabel.SetText(fmt.Sprintf("BT scan failed: %v", err))
-		return
-	}
-
-	a.btDevices = devices
-
-	// Build display list
-	options := make([]string, len(devices))
-	for i, d := range devices {
-		options[i] = fmt.Sprintf("%s (%s)", d.Name, d.MAC)
-	}
-
-	a.btDeviceSelect.Options = options
-	if len(options) > 0 {
-		// Try to auto-select a Nelko device if present
-		selectedIdx := 0
-		for i, d := range devices {
-			if strings.Contains(strings.ToLower(d.Name), "nelko") ||
-				strings.Contains(strings.ToLower(d.Name), "p21") {
-				selectedIdx = i
-				break
-			}
-		}
-		a.btDeviceSelect.SetSelected(options[selectedIdx])
-	}
-
-	a.statusLabel.SetText(fmt.Sprintf("Found %d paired device(s)", len(devices)))
-}
-
-func (a *App) refreshPorts() {
-	// Look for /dev/rfcomm* devices
-	ports, _ := printer.FindRFCOMMDevices()
-
-	// Also add common serial ports
-	commonPorts := []string{"/dev/rfcomm0", "/dev/rfcomm1", "/dev/ttyUSB0", "/dev/ttyACM0"}
-	for _, p := range commonPorts {
-		if _, err := os.Stat(p); err == nil {
-			found := false
-			for _, existing := range ports {
-				if existing == p {
-					found = true
-					break
-				}
-			}
-			if !found {
-				ports = append(ports, p)
-			}
-		}
-	}
-
-	a.portSelect.Options = ports
-	if len(ports) > 0 {
-		a.portSelect.SetSelected(ports[0])
-	}
-}
-
-func (a *App) getSelectedBluetoothDevice() *printer.BluetoothDevice {
-	selectedIdx := a.btDeviceSelect.SelectedIndex()
-	if selectedIdx < 0 || selectedIdx >= len(a.btDevices) {
-		return nil
-	}
-	return &a.btDevices[selectedIdx]
-}
-
-func (a *App) connectBluetooth() {
-	// If already connected, disconnect
-	if a.printer != nil {
-		a.disconnect()
-		return
-	}
-
-	device := a.getSelectedBluetoothDevice()
-	if device == nil {
-		dialog.ShowError(fmt.Errorf("no Bluetooth device selected"), a.window)
-		return
-	}
-
-	// Check for rfcomm
-	if err := printer.CheckRFCOMMInstalled(); err != nil {
-		dialog.ShowError(err, a.window)
-		return
-	}
-
-	// Check for privilege helper
-	helper := printer.CheckPrivilegeHelper()
-	if helper == "" {
-		dialog.ShowError(fmt.Errorf("no privilege helper found (need pkexec or sudo)"), a.window)
-		return
-	}
-
-	// Disable button during connection
-	a.connectBtn.Disable()
-	a.btDeviceSelect.Disable()
-	a.refreshBTBtn.Disable()
-
-	go func() {
-		a.statusLabel.SetText(fmt.Sprintf("Connecting to %s...", device.Name))
-
-		// Establish RFCOMM connection
-		conn, err := printer.EstablishRFCOMM(device.MAC, 1, func(status string) {
-			a.statusLabel.SetText(status)
-		})
-
-		if err != nil {
-			a.statusLabel.SetText(fmt.Sprintf("Connection failed: %v", err))
-			a.connectBtn.Enable()
-			a.btDeviceSelect.Enable()
-			a.refreshBTBtn.Enable()
-
-			// Show error dialog
-			dialog.ShowError(fmt.Errorf("failed to connect: %v", err), a.window)
-			return
-		}
-
-		a.rfcommConn = conn
-
-		// Now connect to the serial port
-		p, err := printer.Connect(conn.DevicePath)
-		if err != nil {
-			conn.Close()
-			a.rfcommConn = nil
-			a.statusLabel.SetText(fmt.Sprintf("Serial connect failed: %v", err))
-			a.connectBtn.Enable()
-			a.btDeviceSelect.Enable()
-			a.refreshBTBtn.Enable()
-			dialog.ShowError(err, a.window)
-			return
-		}
-
-		a.printer = p
-		a.connectBtn.SetText("Disconnect")
-		a.connectBtn.Enable()
-		a.statusLabel.SetText(fmt.Sprintf("Connected to %s via %s", device.Name, conn.DevicePath))
-
-		// Try to get battery
-		if batt, err := p.GetBattery(); err == nil {
-			a.statusLabel.SetText(fmt.Sprintf("Connected to %s (Battery: %d%%)", device.Name, batt))
-		}
-
-		if a.sourceImg != nil {
-			a.printBtn.Enable()
-		}
-
-		// Refresh ports list to show the new device
-		a.refreshPorts()
-	}()
-}
-
-func (a *App) connectManualPort() {
-	// If already connected, disconnect
-	if a.printer != nil {
-		a.disconnect()
-		return
-	}
-
-	port := a.portSelect.Selected
-	if port == "" {
-		dialog.ShowError(fmt.Errorf("no port selected"), a.window)
-		return
-	}
-
-	p, err := printer.Connect(port)
-	if err != nil {
-		dialog.ShowError(err, a.window)
-		return
-	}
-
-	a.printer = p
-	a.connectBtn.SetText("Disconnect")
-	a.statusLabel.SetText(fmt.Sprintf("Connected to %s", port))
-
-	// Try to get battery
-	if batt, err := p.GetBattery(); err == nil {
-		a.statusLabel.SetText(fmt.Sprintf("Connected to %s (Battery: %d%%)", port, batt))
-	}
-
-	if a.sourceImg != nil {
-		a.printBtn.Enable()
-	}
-}
-
-func (a *App) disconnect() {
-	if a.printer != nil {
-		a.printer.Close()
-		a.printer = nil
-	}
-
-	if a.rfcommConn != nil {
-		a.rfcommConn.Close()
-		a.rfcommConn = nil
-	}
-
-	a.connectBtn.SetText("Connect")
-	a.btDeviceSelect.Enable()
-	a.refreshBTBtn.Enable()
-	a.statusLabel.SetText("Disconnected")
-	a.printBtn.Disable()
-}
-
 func (a *App) loadImage() {
 	fd := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
 		if err != nil {
@@ -559,35 +830,103 @@ func (a *App) loadImage() {
 		}
 
 		a.sourceImg = img
+		a.bannerPages = nil
+		a.textSource = false
 		a.updatePreview()
-
-		if a.printer != nil {
-			a.printBtn.Enable()
-		}
+		a.updatePrintButton()
 	}, a.window)
 
 	fd.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".jpg", ".jpeg", ".gif", ".bmp", ".webp"}))
 	fd.Show()
 }
 
+// setModel switches the printer model and lists the label sizes it supports
+func (a *App) setModel(m tspl.Model) {
+	a.model = m
+	if c := a.targetConnection(); c != nil {
+		c.printer.SetModel(m)
+	}
+
+	sizes := a.sizesForModel(m)
+	options := make([]string, len(sizes))
+	keep := false
+	for i, s := range sizes {
+		options[i] = s.Name
+		if s.Name == a.labelSize.Name {
+			keep = true
+		}
+	}
+	a.sizeSelect.Options = options
+
+	if keep {
+		a.sizeSelect.SetSelected(a.labelSize.Name)
+	} else {
+		a.sizeSelect.SetSelected(m.DefaultSize.Name)
+	}
+	a.sizeSelect.Refresh()
+
+	// Menu items depend on the commands the model supports
+	if a.window.MainMenu() != nil {
+		a.window.SetMainMenu(a.buildMenu())
+	}
+}
+
 func (a *App) updatePreview() {
 	if a.sourceImg == nil {
 		return
 	}
 
-	// Convert to monochrome for preview
-	mono := imaging.ToMonochrome(a.sourceImg, a.labelSize.PixelW, a.labelSize.PixelH, a.threshold, a.invert)
-	preview := imaging.PreviewMonochrome(mono, a.labelSize.PixelW, a.labelSize.PixelH)
+	pages := a.bannerPages
+	if len(pages) == 0 {
+		pages = []image.Image{a.sourceImg}
+	}
+
+	previews := make([]image.Image, len(pages))
+	for i, page := range pages {
+		// Convert to monochrome for preview
+		mono := imaging.ToMonochrome(a.transform(page), a.labelSize.PixelW, a.labelSize.PixelH, a.threshold, a.invert)
+		preview := imaging.PreviewMonochrome(mono)
+		preview = imaging.PreviewCorners(preview, tspl.MMToDots(a.labelSize.CornerRadius, a.model.DotsPerMM))
 
-	// For vertical orientation, rotate the preview so text is readable on screen
-	if a.orientation == imaging.Vertical {
-		preview = imaging.RotatePreviewForDisplay(preview)
+		// Sideways text is turned back so it is readable on screen
+		previews[i] = imaging.RotatePreviewForDisplay(preview, a.orientation)
 	}
 
-	a.previewImg.Image = preview
+	// Banner labels are shown in reading order: along the line for sideways
+	// text, one below the other otherwise
+	a.previewImg.Image = imaging.JoinPreviews(previews, a.orientation.Sideways(), 4)
 	a.previewImg.Refresh()
 }
 
+// transform rotates and mirrors an image as set in the UI; corners uncovered
+// by a rotation take the label's background
+func (a *App) transform(img image.Image) image.Image {
+	t := imaging.Transform{
+		Angle:   a.fineAngle,
+		MirrorH: a.mirrorH,
+		MirrorV: a.mirrorV,
+		Fill:    a.background(),
+	}
+	if !a.textSource {
+		t.Angle += a.quarterTurn
+	}
+	if t.IsIdentity() {
+		return img
+	}
+	return t.Apply(img)
+}
+
+// background returns the colour that prints as the label's background
+// before the monochrome conversion: paper white, or black behind inverted
+// text, swapped if the conversion inverts
+func (a *App) background() color.Color {
+	black := a.textSource && a.textInvert
+	if black != a.invert {
+		return color.Black
+	}
+	return color.White
+}
+
 func (a *App) updateTextPreview() {
 	text := a.textEntry.Text
 	if text == "" {
@@ -599,6 +938,68 @@ func (a *App) updateTextPreview() {
 		Orientation:   a.orientation,
 		Invert:        a.textInvert,
 		WordBreakOnly: a.wordBreakOnly,
+		Markup:        a.textMarkup,
+		Hyphenate:     a.hyphenate,
+		Balanced:      a.balanced,
+		FontFamily:    a.fontFamily,
+		FontStyle:     a.fontStyle,
+		Bold:          a.textBold,
+		Italic:        a.textItalic,
+		Condensed:     a.textCondensed,
+		Fit:           a.fit,
+		MinFontSize:   a.fitMin,
+		MaxFontSize:   a.fitMax,
+		ShrinkOnly:    a.fitShrinkOnly,
+		Align:         a.textAlign,
+		VAlign:        a.textVAlign,
+		LineSpacing:   a.lineSpacing,
+		LetterSpacing: a.letterSpacing,
+		Underline:     a.textUnderline,
+		Strike:        a.textStrike,
+		Outline:       a.textOutline,
+		Highlight:     a.textHighlight,
+	}
+	if a.textBox {
+		opts.Box = &imaging.TextBox{
+			Width:   2,
+			Radius:  tspl.MMToDots(a.boxRadiusMM, a.model.DotsPerMM),
+			Padding: 6,
+		}
+	}
+	if a.marginsMM != nil {
+		dots := func(mm float64) int { return tspl.MMToDots(mm, a.model.DotsPerMM) }
+		opts.Margins = &imaging.Margins{
+			Top:    dots(a.marginsMM[0]),
+			Right:  dots(a.marginsMM[1]),
+			Bottom: dots(a.marginsMM[2]),
+			Left:   dots(a.marginsMM[3]),
+		}
+	}
+
+	// Fit once here so the size in use can be shown next to the slider
+	if a.fit && !a.banner {
+		if size, err := imaging.FitFontSize(text, a.labelSize.PixelW, a.labelSize.PixelH, opts); err == nil {
+			opts.FontSize = size
+			opts.Fit = false
+		}
+	}
+	a.fontSizeLabel.SetText(fmt.Sprintf("%g pt", opts.FontSize))
+
+	if a.banner {
+		banner := a.bannerOpts
+		banner.Overlap = tspl.MMToDots(a.bannerOverlapMM, a.model.DotsPerMM)
+		pages, err := imaging.RenderBanner(text, a.labelSize.PixelW, a.labelSize.PixelH, opts, banner)
+		if err != nil {
+			return
+		}
+		a.sourceImg = pages[0]
+		a.bannerPages = pages
+		a.textSource = true
+		a.statusLabel.SetText(fmt.Sprintf("Banner: %d label(s)", len(pages)))
+		a.warnMissingGlyphs(text, opts)
+		a.updatePreview()
+		a.updatePrintButton()
+		return
 	}
 
 	img, err := imaging.RenderTextWithOptions(text, a.labelSize.PixelW, a.labelSize.PixelH, opts)
@@ -607,15 +1008,26 @@ func (a *App) updateTextPreview() {
 	}
 
 	a.sourceImg = img
+	a.bannerPages = nil
+	a.textSource = true
+	a.warnMissingGlyphs(text, opts)
 	a.updatePreview()
+	a.updatePrintButton()
+}
 
-	if a.printer != nil {
-		a.printBtn.Enable()
+// warnMissingGlyphs reports characters that no installed font can draw, since
+// they would silently be left off the label
+func (a *App) warnMissingGlyphs(text string, opts imaging.TextOptions) {
+	missing, err := imaging.MissingGlyphs(text, opts)
+	if err != nil || len(missing) == 0 {
+		return
 	}
+	a.statusLabel.SetText(fmt.Sprintf("Warning: no installed font has %s", strings.Join(strings.Split(string(missing), ""), " ")))
 }
 
 func (a *App) print() {
-	if a.printer == nil {
+	conn := a.targetConnection()
+	if conn == nil {
 		dialog.ShowError(fmt.Errorf("not connected to printer"), a.window)
 		return
 	}
@@ -625,18 +1037,40 @@ func (a *App) print() {
 		return
 	}
 
-	// Convert image to bitmap
-	bitmap := imaging.ToMonochrome(a.sourceImg, a.labelSize.PixelW, a.labelSize.PixelH, a.threshold, !a.invert)
+	settings := tspl.JobSettings{
+		Model:    a.model,
+		Size:     a.labelSize,
+		Media:    a.media,
+		Density:  a.density,
+		Compress: a.compressEnabled(),
+	}
 
-	// Build print job
-	job := tspl.BuildPrintJob(a.labelSize, a.density, bitmap, a.copies)
+	// Build print job; a banner prints each of its labels in order, and
+	// copies repeat the whole banner rather than each label
+	job := tspl.NewJob(settings)
+	if len(a.bannerPages) > 0 {
+		bitmaps := make([]*imaging.Bitmap, len(a.bannerPages))
+		for i, page := range a.bannerPages {
+			bitmaps[i] = imaging.ToMonochrome(a.transform(page), a.labelSize.PixelW, a.labelSize.PixelH, a.threshold, a.invert)
+		}
+		for n := 0; n < a.copies; n++ {
+			for _, bitmap := range bitmaps {
+				job.AddLabel(bitmap, 1)
+			}
+		}
+	} else {
+		// Convert image to bitmap
+		bitmap := imaging.ToMonochrome(a.transform(a.sourceImg), a.labelSize.PixelW, a.labelSize.PixelH, a.threshold, a.invert)
+		job.AddLabel(bitmap, a.copies)
+	}
 
 	// Send to printer
-	a.statusLabel.SetText("Printing...")
+	name := a.connectionLabel(conn)
+	a.statusLabel.SetText(fmt.Sprintf("Printing on %s...", name))
 	a.printBtn.Disable()
 
 	go func() {
-		err := a.printer.Print(job)
+		err := conn.printer.PrintJob(job)
 
 		// Update UI on main thread
 		a.window.Canvas().Refresh(a.statusLabel)
@@ -644,8 +1078,8 @@ func (a *App) print() {
 		if err != nil {
 			a.statusLabel.SetText(fmt.Sprintf("Print error: %v", err))
 		} else {
-			a.statusLabel.SetText("Print complete!")
+			a.statusLabel.SetText(fmt.Sprintf("Print complete on %s!", name))
 		}
-		a.printBtn.Enable()
+		a.updatePrintButton()
 	}()
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+
+	"nelko-print/internal/tspl"
+)
+
+// mediaForm holds the widgets of the "Media" section
+type mediaForm struct {
+	typeSelect *widget.Select
+	gap        *widget.Entry
+	gapOffset  *widget.Entry
+	reverse    *widget.Check
+	mirror     *widget.Check
+	refX       *widget.Entry
+	refY       *widget.Entry
+	shift      *widget.Entry
+	feed       *widget.Entry
+
+	updating bool // set while widgets are filled from a Media value
+}
+
+// loadMedia reads the last used media settings
+func (a *App) loadMedia() tspl.Media {
+	var m tspl.Media
+	raw := a.fyneApp.Preferences().String(prefMedia)
+	if raw != "" {
+		json.Unmarshal([]byte(raw), &m)
+	}
+	return m
+}
+
+// saveMedia writes the media settings to preferences
+func (a *App) saveMedia() {
+	data, err := json.Marshal(a.media)
+	if err != nil {
+		return
+	}
+	a.fyneApp.Preferences().SetString(prefMedia, string(data))
+}
+
+// buildMediaSection creates the media type, gap and calibration controls
+func (a *App) buildMediaSection() fyne.CanvasObject {
+	f := &mediaForm{}
+	a.mediaForm = f
+
+	f.typeSelect = widget.NewSelect(tspl.MediaTypeNames, func(s string) {
+		for i, name := range tspl.MediaTypeNames {
+			if name == s {
+				a.media.Type = tspl.MediaType(i)
+			}
+		}
+		a.mediaChanged()
+	})
+
+	// An empty entry leaves the setting unset, so the default applies
+	mmEntry := func(placeholder string, value **float64) *widget.Entry {
+		e := widget.NewEntry()
+		e.SetPlaceHolder(placeholder)
+		e.OnChanged = func(s string) {
+			if s == "" {
+				*value = nil
+				a.mediaChanged()
+				return
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return
+			}
+			*value = tspl.MM(v)
+			a.mediaChanged()
+		}
+		return e
+	}
+
+	f.gap = mmEntry("default", &a.media.Gap)
+	f.gapOffset = mmEntry("label default", &a.media.GapOffset)
+	f.refX = mmEntry("printer setting", &a.media.ReferenceX)
+	f.refY = mmEntry("printer setting", &a.media.ReferenceY)
+	f.shift = mmEntry("printer setting (+ down, - up)", &a.media.ShiftY)
+	f.feed = mmEntry("printer setting", &a.media.FeedOffset)
+
+	f.reverse = widget.NewCheck("Rotate 180°", func(b bool) {
+		a.media.Reverse = b
+		a.mediaChanged()
+	})
+	f.mirror = widget.NewCheck("Mirror", func(b bool) {
+		a.media.Mirror = b
+		a.mediaChanged()
+	})
+
+	a.setMedia(a.media)
+
+	return widget.NewForm(
+		widget.NewFormItem("Type", f.typeSelect),
+		widget.NewFormItem("Gap / Mark (mm)", f.gap),
+		widget.NewFormItem("Gap Offset (mm)", f.gapOffset),
+		widget.NewFormItem("Direction", f.reverse),
+		widget.NewFormItem("", f.mirror),
+		widget.NewFormItem("Reference X (mm)", f.refX),
+		widget.NewFormItem("Reference Y (mm)", f.refY),
+		widget.NewFormItem("Vertical Shift (mm)", f.shift),
+		widget.NewFormItem("Feed Offset (mm)", f.feed),
+	)
+}
+
+// setMedia replaces the media settings and shows them in the Media section
+func (a *App) setMedia(m tspl.Media) {
+	a.media = m
+
+	f := a.mediaForm
+	if f == nil {
+		return
+	}
+	f.updating = true
+	defer func() { f.updating = false }()
+
+	mmText := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprintf("%g", *v)
+	}
+
+	f.typeSelect.SetSelected(m.Type.String())
+	f.gap.SetText(mmText(m.Gap))
+	f.gapOffset.SetText(mmText(m.GapOffset))
+	f.reverse.SetChecked(m.Reverse)
+	f.mirror.SetChecked(m.Mirror)
+	f.refX.SetText(mmText(m.ReferenceX))
+	f.refY.SetText(mmText(m.ReferenceY))
+	f.shift.SetText(mmText(m.ShiftY))
+	f.feed.SetText(mmText(m.FeedOffset))
+
+	// Widget callbacks fire while filling in; restore the exact value
+	a.media = m
+	a.saveMedia()
+}
+
+// mediaChanged saves edits made in the Media section
+func (a *App) mediaChanged() {
+	if a.mediaForm == nil || a.mediaForm.updating {
+		return
+	}
+	a.saveMedia()
+}
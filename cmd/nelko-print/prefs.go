@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Preference keys
+const (
+	prefLastTransport = "lastDevice.transport"
+	prefLastAddress   = "lastDevice.address"
+	prefLastName      = "lastDevice.name"
+	prefLastChannel   = "lastDevice.channel"
+	prefAutoConnect   = "autoConnect"
+	prefProfiles      = "printerProfiles"
+	prefCustomSizes   = "customLabelSizes"
+	prefMedia         = "media"
+	prefFontFamily    = "text.fontFamily"
+	prefFontStyle     = "text.fontStyle"
+	prefCompress      = "compressBitmaps"
+)
+
+// Transport types for a saved device
+const (
+	transportBluetooth = "bluetooth" // rfcomm on Linux, Bluetooth COM port on Windows
+	transportPort      = "port"      // manually selected serial port
+)
+
+// defaultRFCOMMChannel is the SPP channel the P21 listens on
+const defaultRFCOMMChannel = 1
+
+// RFCOMM channels run from 1 to 30
+const (
+	minRFCOMMChannel = 1
+	maxRFCOMMChannel = 30
+)
+
+// parseRFCOMMChannel reads an RFCOMM channel number typed by the user
+func parseRFCOMMChannel(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < minRFCOMMChannel || n > maxRFCOMMChannel {
+		return 0, fmt.Errorf("RFCOMM channel must be a number from %d to %d", minRFCOMMChannel, maxRFCOMMChannel)
+	}
+	return n, nil
+}
+
+// savedDevice is the last printer connection that succeeded
+type savedDevice struct {
+	Transport string
+	Address   string // MAC on Linux, COM port on Windows, or serial port path
+	Name      string
+	Channel   int // RFCOMM channel (ignored on Windows and for manual ports)
+}
+
+// loadLastDevice returns the last successfully used device, if any
+func (a *App) loadLastDevice() (savedDevice, bool) {
+	prefs := a.fyneApp.Preferences()
+
+	d := savedDevice{
+		Transport: prefs.String(prefLastTransport),
+		Address:   prefs.String(prefLastAddress),
+		Name:      prefs.String(prefLastName),
+		Channel:   prefs.IntWithFallback(prefLastChannel, defaultRFCOMMChannel),
+	}
+	if d.Transport == "" || d.Address == "" {
+		return savedDevice{}, false
+	}
+	return d, true
+}
+
+// saveLastDevice remembers a device after a successful connection
+func (a *App) saveLastDevice(d savedDevice) {
+	prefs := a.fyneApp.Preferences()
+	prefs.SetString(prefLastTransport, d.Transport)
+	prefs.SetString(prefLastAddress, d.Address)
+	prefs.SetString(prefLastName, d.Name)
+	prefs.SetInt(prefLastChannel, d.Channel)
+}
+
+// autoConnectEnabled reports whether the last device should be connected on startup
+func (a *App) autoConnectEnabled() bool {
+	return a.fyneApp.Preferences().Bool(prefAutoConnect)
+}
+
+func (a *App) setAutoConnect(enabled bool) {
+	a.fyneApp.Preferences().SetBool(prefAutoConnect, enabled)
+}
+
+// compressEnabled reports whether print jobs send run-length encoded bitmaps
+func (a *App) compressEnabled() bool {
+	return a.fyneApp.Preferences().Bool(prefCompress)
+}
+
+func (a *App) setCompress(enabled bool) {
+	a.fyneApp.Preferences().SetBool(prefCompress, enabled)
+}
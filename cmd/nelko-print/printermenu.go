@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+
+	"nelko-print/internal/printer"
+	"nelko-print/internal/tspl"
+)
+
+// buildPrinterMenu creates the "Printer" menu with calibration and utility commands
+// Only the commands the current model supports are listed
+func (a *App) buildPrinterMenu() *fyne.Menu {
+	item := func(label string, feature tspl.Feature, action func()) *fyne.MenuItem {
+		if !a.model.Supports(feature) {
+			return nil
+		}
+		return fyne.NewMenuItem(label, action)
+	}
+
+	calibrate := item("Calibrate Media", tspl.FeatureGapDetect, func() {
+		a.runPrinterCommand("Calibrating media", func(p *printer.Printer) error {
+			return p.Calibrate(a.media.Type)
+		})
+	})
+	home := item("Feed to Next Label", tspl.FeatureHome, func() {
+		a.runPrinterCommand("Feeding", (*printer.Printer).Home)
+	})
+	formFeed := item("Form Feed", tspl.FeatureHome, func() {
+		a.runPrinterCommand("Feeding", (*printer.Printer).FormFeed)
+	})
+	selfTest := item("Print Self-Test", tspl.FeatureSelfTest, func() {
+		a.runPrinterCommand("Printing self-test", (*printer.Printer).SelfTest)
+	})
+
+	tear := item("Tear Mode", tspl.FeatureTear, nil)
+	if tear != nil {
+		tear.ChildMenu = fyne.NewMenu("",
+			fyne.NewMenuItem("On", func() {
+				a.runPrinterCommand("Enabling tear mode", func(p *printer.Printer) error {
+					return p.SetTear(true)
+				})
+			}),
+			fyne.NewMenuItem("Off", func() {
+				a.runPrinterCommand("Disabling tear mode", func(p *printer.Printer) error {
+					return p.SetTear(false)
+				})
+			}),
+		)
+	}
+
+	speed := item("Print Speed", tspl.FeatureSpeed, nil)
+	if len(a.model.Speeds) == 0 {
+		speed = nil
+	}
+	if speed != nil {
+		speed.ChildMenu = fyne.NewMenu("")
+		for _, s := range a.model.Speeds {
+			ips := s
+			speed.ChildMenu.Items = append(speed.ChildMenu.Items, fyne.NewMenuItem(fmt.Sprintf("%g ips", ips), func() {
+				a.runPrinterCommand(fmt.Sprintf("Setting speed to %g ips", ips), func(p *printer.Printer) error {
+					return p.SetSpeed(ips)
+				})
+			}))
+		}
+	}
+
+	reset := item("Reset to Factory Settings...", tspl.FeatureInitialize, func() {
+		dialog.ShowConfirm("Reset Printer", "Restore the printer's factory settings?", func(ok bool) {
+			if ok {
+				a.runPrinterCommand("Resetting printer", (*printer.Printer).Initialize)
+			}
+		}, a.window)
+	})
+
+	return fyne.NewMenu("Printer", menuItems(
+		[]*fyne.MenuItem{calibrate, home, formFeed, selfTest},
+		[]*fyne.MenuItem{tear, speed},
+		[]*fyne.MenuItem{reset},
+	)...)
+}
+
+// menuItems joins groups of menu items with separators, leaving out nil
+// items and empty groups
+func menuItems(groups ...[]*fyne.MenuItem) []*fyne.MenuItem {
+	var items []*fyne.MenuItem
+	for _, group := range groups {
+		first := true
+		for _, mi := range group {
+			if mi == nil {
+				continue
+			}
+			if first && len(items) > 0 {
+				items = append(items, fyne.NewMenuItemSeparator())
+			}
+			first = false
+			items = append(items, mi)
+		}
+	}
+	return items
+}
+
+// runPrinterCommand runs a maintenance command on the print target in the background
+func (a *App) runPrinterCommand(action string, run func(p *printer.Printer) error) {
+	conn := a.targetConnection()
+	if conn == nil {
+		dialog.ShowError(fmt.Errorf("not connected to printer"), a.window)
+		return
+	}
+
+	name := a.connectionLabel(conn)
+	a.statusLabel.SetText(fmt.Sprintf("%s on %s...", action, name))
+
+	go func() {
+		if err := run(conn.printer); err != nil {
+			a.statusLabel.SetText(fmt.Sprintf("%s failed: %v", action, err))
+			return
+		}
+		a.statusLabel.SetText(fmt.Sprintf("%s on %s: done", action, name))
+	}()
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"nelko-print/internal/tspl"
+)
+
+// printerProfile is a named printer with its own device and print defaults
+type printerProfile struct {
+	Name      string
+	Device    savedDevice
+	Model     string // tspl.Model name
+	LabelSize string // tspl.LabelSize name
+	Media     tspl.Media
+	Density   int
+}
+
+// loadProfiles reads the saved printer profiles
+func (a *App) loadProfiles() []printerProfile {
+	var profiles []printerProfile
+	raw := a.fyneApp.Preferences().String(prefProfiles)
+	if raw == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(raw), &profiles); err != nil {
+		return nil
+	}
+	return profiles
+}
+
+// saveProfiles writes the printer profiles to preferences
+func (a *App) saveProfiles() {
+	data, err := json.Marshal(a.profiles)
+	if err != nil {
+		return
+	}
+	a.fyneApp.Preferences().SetString(prefProfiles, string(data))
+}
+
+// profileByName returns the profile with the given name, or nil
+func (a *App) profileByName(name string) *printerProfile {
+	for i := range a.profiles {
+		if a.profiles[i].Name == name {
+			return &a.profiles[i]
+		}
+	}
+	return nil
+}
+
+// profileForDevice returns the first profile using the given device address, or nil
+func (a *App) profileForDevice(address string) *printerProfile {
+	for i := range a.profiles {
+		if a.profiles[i].Device.Address == address {
+			return &a.profiles[i]
+		}
+	}
+	return nil
+}
+
+// refreshProfiles rebuilds the profile list
+func (a *App) refreshProfiles() {
+	options := make([]string, len(a.profiles))
+	for i, p := range a.profiles {
+		options[i] = p.Name
+	}
+	a.profileSelect.Options = options
+	if a.profileByName(a.profileSelect.Selected) == nil {
+		a.profileSelect.ClearSelected()
+	}
+	a.profileSelect.Refresh()
+}
+
+// selectProfile selects the profile's device and applies its print settings
+func (a *App) selectProfile(name string) {
+	p := a.profileByName(name)
+	if p == nil {
+		return
+	}
+
+	switch p.Device.Transport {
+	case transportBluetooth:
+		for i, d := range a.btDevices {
+			if d.MAC == p.Device.Address {
+				a.btDeviceSelect.SetSelectedIndex(i)
+				break
+			}
+		}
+	case transportPort:
+		a.selectPort(p.Device.Address)
+	}
+
+	if a.connection(p.Device.Address) != nil {
+		a.setTarget(p.Device.Address)
+	} else {
+		a.applyProfileSettings(*p)
+	}
+}
+
+// connectProfile selects a profile by name and connects to its printer, which
+// becomes the print target; used for the --printer option
+func (a *App) connectProfile(name string) {
+	p := a.profileByName(name)
+	if p == nil {
+		a.statusLabel.SetText(fmt.Sprintf("No printer profile named %q", name))
+		return
+	}
+
+	a.profileSelect.SetSelected(p.Name)
+	if a.connection(p.Device.Address) != nil {
+		return
+	}
+	if !a.connectDevice(p.Device) {
+		a.statusLabel.SetText(fmt.Sprintf("Printer %s of profile %q not found", p.Device.Name, p.Name))
+	}
+}
+
+// applyProfileSettings switches model, label size and density to the profile's defaults
+func (a *App) applyProfileSettings(p printerProfile) {
+	if p.Model != "" {
+		a.modelSelect.SetSelected(tspl.ModelByName(p.Model).Name)
+	}
+	for _, size := range a.sizesForModel(a.model) {
+		if size.Name == p.LabelSize {
+			a.sizeSelect.SetSelected(size.Name)
+			break
+		}
+	}
+	a.densitySlider.SetValue(float64(p.Density))
+	a.setMedia(p.Media)
+}
+
+// currentDevice returns the device a new profile should use: the print target
+// if one is connected, otherwise the selected Bluetooth device
+func (a *App) currentDevice() (savedDevice, bool) {
+	if c := a.targetConnection(); c != nil {
+		return c.device, true
+	}
+	if d := a.getSelectedBluetoothDevice(); d != nil {
+		return savedDevice{
+			Transport: transportBluetooth,
+			Address:   d.MAC,
+			Name:      d.Name,
+			Channel:   a.rfcommChannel,
+		}, true
+	}
+	return savedDevice{}, false
+}
+
+// showSaveProfileDialog saves the current device, label size and density as a named profile
+func (a *App) showSaveProfileDialog() {
+	device, ok := a.currentDevice()
+	if !ok {
+		dialog.ShowError(fmt.Errorf("no printer selected"), a.window)
+		return
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(a.profileSelect.Selected)
+	nameEntry.SetPlaceHolder("e.g. Desk left")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Printer", widget.NewLabel(device.Name)),
+	}
+
+	dialog.ShowForm("Save Printer Profile", "Save", "Cancel", items, func(ok bool) {
+		if !ok || nameEntry.Text == "" {
+			return
+		}
+
+		profile := printerProfile{
+			Name:      nameEntry.Text,
+			Device:    device,
+			Model:     a.model.Name,
+			LabelSize: a.labelSize.Name,
+			Media:     a.media,
+			Density:   a.density,
+		}
+		if existing := a.profileByName(profile.Name); existing != nil {
+			*existing = profile
+		} else {
+			a.profiles = append(a.profiles, profile)
+		}
+		a.saveProfiles()
+		a.refreshProfiles()
+		a.profileSelect.SetSelected(profile.Name)
+		a.refreshTargets()
+	}, a.window)
+}
+
+// deleteSelectedProfile removes the selected profile after confirmation
+func (a *App) deleteSelectedProfile() {
+	name := a.profileSelect.Selected
+	if name == "" {
+		return
+	}
+
+	dialog.ShowConfirm("Delete Profile", fmt.Sprintf("Delete printer profile %q?", name), func(ok bool) {
+		if !ok {
+			return
+		}
+		for i, p := range a.profiles {
+			if p.Name == name {
+				a.profiles = append(a.profiles[:i], a.profiles[i+1:]...)
+				break
+			}
+		}
+		a.saveProfiles()
+		a.profileSelect.ClearSelected()
+		a.refreshProfiles()
+		a.refreshTargets()
+	}, a.window)
+}
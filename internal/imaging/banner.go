@@ -0,0 +1,199 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// BannerOptions configures splitting content that is too long for one label
+type BannerOptions struct {
+	Overlap     int  // pixels repeated at the start of each following label
+	JoinMarks   bool // dashed lines showing where neighbouring labels overlap
+	PageNumbers bool // print "n/N" in the corner of each label
+}
+
+// RenderBanner lays text out on one strip as long as the text needs and slices
+// it into consecutive width x height labels
+// With a sideways orientation the text runs along the tape, otherwise lines
+// are stacked along it
+func RenderBanner(text string, width, height int, opts TextOptions, banner BannerOptions) ([]image.Image, error) {
+	vertical := opts.Orientation.Sideways()
+
+	// Render unrotated so slicing and decoration happen in reading direction
+	flat := opts
+	flat.Orientation = Horizontal
+	flat.Fit = false // the banner grows to fit the text instead
+
+	// The strip is as long as the text plus the margins before and after it
+	m := flat.margins()
+	maxWidth := width - m.Left - m.Right
+	if vertical {
+		// One line per paragraph, however long
+		maxWidth = math.MaxInt32
+	}
+	w, h, err := measureTextBlock(text, maxWidth, flat)
+	if err != nil {
+		return nil, err
+	}
+
+	var strip image.Image
+	if vertical {
+		strip, err = RenderTextWithOptions(text, max(height, w+m.Left+m.Right), width, flat)
+	} else {
+		strip, err = RenderTextWithOptions(text, width, max(height, h+m.Top+m.Bottom), flat)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pages := sliceStrip(strip, height, banner, vertical, opts.Invert)
+	for i, p := range pages {
+		pages[i] = opts.Orientation.orient(p)
+	}
+	return pages, nil
+}
+
+// sliceStrip cuts a strip into pages of the given length along x (alongX) or y
+func sliceStrip(strip image.Image, length int, banner BannerOptions, alongX, invert bool) []image.Image {
+	bounds := strip.Bounds()
+	total := bounds.Dy()
+	if alongX {
+		total = bounds.Dx()
+	}
+
+	overlap := banner.Overlap
+	if overlap < 0 || overlap >= length {
+		overlap = 0
+	}
+	step := length - overlap
+
+	count := 1
+	if total > length {
+		count = (total - overlap + step - 1) / step
+	}
+
+	bg, fg := color.Color(color.White), color.Color(color.Black)
+	if invert {
+		bg, fg = fg, bg
+	}
+
+	pages := make([]image.Image, count)
+	for i := 0; i < count; i++ {
+		var page *image.RGBA
+		var src image.Point
+		if alongX {
+			page = image.NewRGBA(image.Rect(0, 0, length, bounds.Dy()))
+			src = image.Pt(bounds.Min.X+i*step, bounds.Min.Y)
+		} else {
+			page = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), length))
+			src = image.Pt(bounds.Min.X, bounds.Min.Y+i*step)
+		}
+		// The last page may run past the strip; fill it with the background
+		draw.Draw(page, page.Bounds(), &image.Uniform{bg}, image.Point{}, draw.Src)
+		draw.Draw(page, page.Bounds(), strip, src, draw.Src)
+
+		if banner.JoinMarks && overlap > 0 {
+			if i > 0 {
+				drawJoinMark(page, overlap, alongX, fg)
+			}
+			if i < count-1 {
+				drawJoinMark(page, length-overlap, alongX, fg)
+			}
+		}
+		if banner.PageNumbers && count > 1 {
+			drawPageNumber(page, fmt.Sprintf("%d/%d", i+1, count), fg, bg)
+		}
+		pages[i] = page
+	}
+
+	return pages
+}
+
+// drawJoinMark draws a dashed line across the page at pos along the slicing axis
+func drawJoinMark(page *image.RGBA, pos int, alongX bool, c color.Color) {
+	b := page.Bounds()
+	across := b.Dx()
+	if alongX {
+		across = b.Dy()
+	}
+	for i := 0; i < across; i++ {
+		if (i/4)%2 == 1 {
+			continue
+		}
+		if alongX {
+			page.Set(pos, i, c)
+		} else {
+			page.Set(i, pos, c)
+		}
+	}
+}
+
+// drawPageNumber writes a small page label in the bottom-right corner
+func drawPageNumber(page *image.RGBA, label string, fg, bg color.Color) {
+	face := basicfont.Face7x13
+	w := font.MeasureString(face, label).Ceil()
+	h := face.Metrics().Height.Ceil()
+
+	b := page.Bounds()
+	box := image.Rect(b.Max.X-w-4, b.Max.Y-h-2, b.Max.X, b.Max.Y)
+	draw.Draw(page, box, &image.Uniform{bg}, image.Point{}, draw.Src)
+
+	d := font.Drawer{
+		Dst:  page,
+		Src:  &image.Uniform{fg},
+		Face: face,
+		Dot:  fixed.P(box.Min.X+2, box.Max.Y-face.Metrics().Descent.Ceil()-1),
+	}
+	d.DrawString(label)
+}
+
+// JoinPreviews places preview images next to each other (or below each other)
+// with a grey gap so multi-label output can be shown in one image
+func JoinPreviews(imgs []image.Image, sideBySide bool, gap int) image.Image {
+	if len(imgs) == 1 {
+		return imgs[0]
+	}
+
+	w, h := 0, 0
+	for _, img := range imgs {
+		b := img.Bounds()
+		if sideBySide {
+			w += b.Dx()
+			h = max(h, b.Dy())
+		} else {
+			w = max(w, b.Dx())
+			h += b.Dy()
+		}
+	}
+	if sideBySide {
+		w += gap * (len(imgs) - 1)
+	} else {
+		h += gap * (len(imgs) - 1)
+	}
+
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{color.Gray{160}}, image.Point{}, draw.Src)
+
+	pos := 0
+	for _, img := range imgs {
+		b := img.Bounds()
+		var r image.Rectangle
+		if sideBySide {
+			r = image.Rect(pos, 0, pos+b.Dx(), b.Dy())
+			pos += b.Dx() + gap
+		} else {
+			r = image.Rect(0, pos, b.Dx(), pos+b.Dy())
+			pos += b.Dy() + gap
+		}
+		draw.Draw(dst, r, img, b.Min, draw.Src)
+	}
+
+	return dst
+}
@@ -0,0 +1,237 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// Bitmap is a packed 1-bit image
+// Rows are Stride bytes, most significant bit first, and a set bit is a black
+// dot; widths need not be a multiple of 8 (unused bits in the last byte of a
+// row stay 0)
+type Bitmap struct {
+	Pix    []byte
+	Stride int
+	Rect   image.Rectangle
+}
+
+// BitmapModel converts colors to black or white at 50% luminance
+var BitmapModel = color.ModelFunc(func(c color.Color) color.Color {
+	if bitmapBlack(c) {
+		return color.Black
+	}
+	return color.White
+})
+
+// bitmapBlack reports whether a color counts as a black dot
+func bitmapBlack(c color.Color) bool {
+	return color.Gray16Model.Convert(c).(color.Gray16).Y < 0x8000
+}
+
+// NewBitmap returns a white bitmap with the given bounds
+func NewBitmap(r image.Rectangle) *Bitmap {
+	stride := (r.Dx() + 7) / 8
+	return &Bitmap{
+		Pix:    make([]byte, stride*r.Dy()),
+		Stride: stride,
+		Rect:   r,
+	}
+}
+
+// ColorModel implements image.Image
+func (b *Bitmap) ColorModel() color.Model {
+	return BitmapModel
+}
+
+// Bounds implements image.Image
+func (b *Bitmap) Bounds() image.Rectangle {
+	return b.Rect
+}
+
+// At implements image.Image
+func (b *Bitmap) At(x, y int) color.Color {
+	if b.Black(x, y) {
+		return color.Black
+	}
+	return color.White
+}
+
+// Set implements draw.Image; colors darker than 50% grey become black dots
+func (b *Bitmap) Set(x, y int, c color.Color) {
+	b.SetBlack(x, y, bitmapBlack(c))
+}
+
+// offset returns the byte index and bit mask of a pixel
+func (b *Bitmap) offset(x, y int) (int, byte) {
+	x -= b.Rect.Min.X
+	y -= b.Rect.Min.Y
+	return y*b.Stride + x>>3, 0x80 >> uint(x&7)
+}
+
+// Black reports whether the pixel at (x, y) is a black dot
+// Pixels outside the bitmap are white
+func (b *Bitmap) Black(x, y int) bool {
+	if !(image.Point{x, y}.In(b.Rect)) {
+		return false
+	}
+	i, mask := b.offset(x, y)
+	return b.Pix[i]&mask != 0
+}
+
+// SetBlack sets the pixel at (x, y) to black or white
+func (b *Bitmap) SetBlack(x, y int, black bool) {
+	if !(image.Point{x, y}.In(b.Rect)) {
+		return
+	}
+	i, mask := b.offset(x, y)
+	if black {
+		b.Pix[i] |= mask
+	} else {
+		b.Pix[i] &^= mask
+	}
+}
+
+// lastByteMask covers the bits of a row's last byte that are inside the bitmap
+func (b *Bitmap) lastByteMask() byte {
+	if rem := b.Rect.Dx() & 7; rem != 0 {
+		return 0xFF << uint(8-rem)
+	}
+	return 0xFF
+}
+
+// Invert swaps black and white
+func (b *Bitmap) Invert() {
+	last := b.lastByteMask()
+	for y := 0; y < b.Rect.Dy(); y++ {
+		row := b.Pix[y*b.Stride : (y+1)*b.Stride]
+		for i := range row {
+			row[i] = ^row[i]
+		}
+		if len(row) > 0 {
+			row[len(row)-1] &= last
+		}
+	}
+}
+
+// RasterOp combines a source dot with the dot already in a bitmap
+type RasterOp int
+
+const (
+	OpCopy RasterOp = iota // replace the destination
+	OpOr                   // black where either is black
+	OpAnd                  // black where both are black
+	OpXor                  // black where exactly one is black
+)
+
+// apply combines destination and source bytes, changing only the bits in mask
+func (op RasterOp) apply(dst, src, mask byte) byte {
+	var v byte
+	switch op {
+	case OpOr:
+		v = dst | src
+	case OpAnd:
+		v = dst & src
+	case OpXor:
+		v = dst ^ src
+	default:
+		v = src
+	}
+	return dst&^mask | v&mask
+}
+
+// Blit combines the r part of src into b with its top-left corner at p
+func (b *Bitmap) Blit(p image.Point, src *Bitmap, r image.Rectangle, op RasterOp) {
+	// Clip to both bitmaps
+	r = r.Intersect(src.Rect)
+	dr := r.Add(p.Sub(r.Min)).Intersect(b.Rect)
+	if dr.Empty() {
+		return
+	}
+	r = dr.Add(r.Min.Sub(p))
+
+	dx0 := dr.Min.X - b.Rect.Min.X
+	sx0 := r.Min.X - src.Rect.Min.X
+	w := dr.Dx()
+
+	for y := 0; y < dr.Dy(); y++ {
+		drow := b.Pix[(dr.Min.Y-b.Rect.Min.Y+y)*b.Stride:]
+		srow := src.Pix[(r.Min.Y-src.Rect.Min.Y+y)*src.Stride:]
+
+		// Byte-aligned rows are combined a byte at a time
+		if dx0&7 == 0 && sx0&7 == 0 {
+			for x := 0; x < w; x += 8 {
+				mask := byte(0xFF)
+				if n := w - x; n < 8 {
+					mask <<= uint(8 - n)
+				}
+				di, si := (dx0+x)>>3, (sx0+x)>>3
+				drow[di] = op.apply(drow[di], srow[si], mask)
+			}
+			continue
+		}
+
+		for x := 0; x < w; x++ {
+			sx, dx := sx0+x, dx0+x
+			var bit byte
+			if srow[sx>>3]&(0x80>>uint(sx&7)) != 0 {
+				bit = 0xFF
+			}
+			mask := byte(0x80) >> uint(dx&7)
+			drow[dx>>3] = op.apply(drow[dx>>3], bit, mask)
+		}
+	}
+}
+
+// Crop returns a copy of the r part of the bitmap, with its origin at (0, 0)
+func (b *Bitmap) Crop(r image.Rectangle) *Bitmap {
+	r = r.Intersect(b.Rect)
+	dst := NewBitmap(image.Rect(0, 0, r.Dx(), r.Dy()))
+	dst.Blit(image.Point{}, b, r, OpCopy)
+	return dst
+}
+
+// transform returns a new w x h bitmap whose dot (x, y) is taken from this
+// bitmap at src(x, y), relative to the bitmap's origin
+func (b *Bitmap) transform(w, h int, src func(x, y int) (int, int)) *Bitmap {
+	dst := NewBitmap(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := src(x, y)
+			if b.Black(b.Rect.Min.X+sx, b.Rect.Min.Y+sy) {
+				i, mask := dst.offset(x, y)
+				dst.Pix[i] |= mask
+			}
+		}
+	}
+	return dst
+}
+
+// Rotate90CW returns the bitmap rotated 90 degrees clockwise
+func (b *Bitmap) Rotate90CW() *Bitmap {
+	w, h := b.Rect.Dx(), b.Rect.Dy()
+	return b.transform(h, w, func(x, y int) (int, int) { return y, h - 1 - x })
+}
+
+// Rotate90CCW returns the bitmap rotated 90 degrees counter-clockwise
+func (b *Bitmap) Rotate90CCW() *Bitmap {
+	w, h := b.Rect.Dx(), b.Rect.Dy()
+	return b.transform(h, w, func(x, y int) (int, int) { return w - 1 - y, x })
+}
+
+// Rotate180 returns the bitmap turned upside down
+func (b *Bitmap) Rotate180() *Bitmap {
+	w, h := b.Rect.Dx(), b.Rect.Dy()
+	return b.transform(w, h, func(x, y int) (int, int) { return w - 1 - x, h - 1 - y })
+}
+
+// FlipH returns the bitmap mirrored left to right
+func (b *Bitmap) FlipH() *Bitmap {
+	w, h := b.Rect.Dx(), b.Rect.Dy()
+	return b.transform(w, h, func(x, y int) (int, int) { return w - 1 - x, y })
+}
+
+// FlipV returns the bitmap mirrored top to bottom
+func (b *Bitmap) FlipV() *Bitmap {
+	w, h := b.Rect.Dx(), b.Rect.Dy()
+	return b.transform(w, h, func(x, y int) (int, int) { return x, h - 1 - y })
+}
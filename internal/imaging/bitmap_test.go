@@ -0,0 +1,189 @@
+package imaging
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// randomBitmap returns a bitmap with bounds r and random dots
+func randomBitmap(rng *rand.Rand, r image.Rectangle) *Bitmap {
+	bm := NewBitmap(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			bm.SetBlack(x, y, rng.Intn(2) == 0)
+		}
+	}
+	return bm
+}
+
+// clone returns a copy of a bitmap
+func clone(b *Bitmap) *Bitmap {
+	c := *b
+	c.Pix = append([]byte(nil), b.Pix...)
+	return &c
+}
+
+// checkBitmap compares two bitmaps dot by dot and checks the unused bits of
+// got are clear
+func checkBitmap(t *testing.T, name string, got, want *Bitmap) {
+	t.Helper()
+	if got.Rect != want.Rect {
+		t.Fatalf("%s: bounds %v, want %v", name, got.Rect, want.Rect)
+	}
+	for y := want.Rect.Min.Y; y < want.Rect.Max.Y; y++ {
+		for x := want.Rect.Min.X; x < want.Rect.Max.X; x++ {
+			if got.Black(x, y) != want.Black(x, y) {
+				t.Fatalf("%s: dot (%d, %d) is %v, want %v", name, x, y, got.Black(x, y), want.Black(x, y))
+			}
+		}
+		last := got.Pix[(y-got.Rect.Min.Y+1)*got.Stride-1]
+		if last&^got.lastByteMask() != 0 {
+			t.Fatalf("%s: unused bits set in row %d: %08b", name, y, last)
+		}
+	}
+}
+
+// blitDots is Blit one dot at a time
+func blitDots(b *Bitmap, p image.Point, src *Bitmap, r image.Rectangle, op RasterOp) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dx, dy := p.X+x-r.Min.X, p.Y+y-r.Min.Y
+			if !(image.Point{x, y}.In(src.Rect)) || !(image.Point{dx, dy}.In(b.Rect)) {
+				continue
+			}
+			d, s := b.Black(dx, dy), src.Black(x, y)
+			switch op {
+			case OpOr:
+				d = d || s
+			case OpAnd:
+				d = d && s
+			case OpXor:
+				d = d != s
+			default:
+				d = s
+			}
+			b.SetBlack(dx, dy, d)
+		}
+	}
+}
+
+func TestBlit(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ops := map[RasterOp]string{OpCopy: "copy", OpOr: "or", OpAnd: "and", OpXor: "xor"}
+	tests := []struct {
+		name string
+		dst  image.Rectangle
+		src  image.Rectangle
+		r    image.Rectangle
+		p    image.Point
+	}{
+		{"aligned", image.Rect(0, 0, 64, 8), image.Rect(0, 0, 32, 8), image.Rect(0, 0, 32, 8), image.Pt(16, 0)},
+		{"aligned odd width", image.Rect(0, 0, 37, 5), image.Rect(0, 0, 21, 5), image.Rect(0, 0, 21, 5), image.Pt(8, 0)},
+		{"unaligned destination", image.Rect(0, 0, 40, 6), image.Rect(0, 0, 16, 6), image.Rect(0, 0, 16, 6), image.Pt(3, 1)},
+		{"unaligned source", image.Rect(0, 0, 40, 6), image.Rect(0, 0, 29, 6), image.Rect(5, 1, 27, 6), image.Pt(0, 0)},
+		{"both unaligned", image.Rect(0, 0, 85, 9), image.Rect(0, 0, 23, 7), image.Rect(1, 0, 22, 7), image.Pt(62, 2)},
+		{"one dot column", image.Rect(0, 0, 13, 4), image.Rect(0, 0, 9, 4), image.Rect(8, 0, 9, 4), image.Pt(12, 0)},
+		{"clipped", image.Rect(0, 0, 20, 5), image.Rect(0, 0, 20, 5), image.Rect(0, 0, 20, 5), image.Pt(-3, 2)},
+		{"offset bounds", image.Rect(10, 10, 31, 15), image.Rect(-4, 3, 15, 8), image.Rect(-4, 3, 15, 8), image.Pt(13, 11)},
+		{"outside", image.Rect(0, 0, 16, 4), image.Rect(0, 0, 8, 4), image.Rect(0, 0, 8, 4), image.Pt(20, 0)},
+	}
+
+	for _, tt := range tests {
+		for op, opName := range ops {
+			dst := randomBitmap(rng, tt.dst)
+			src := randomBitmap(rng, tt.src)
+			want := clone(dst)
+			blitDots(want, tt.p, src, tt.r, op)
+
+			dst.Blit(tt.p, src, tt.r, op)
+			checkBitmap(t, tt.name+" "+opName, dst, want)
+		}
+	}
+}
+
+func TestCrop(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	bm := randomBitmap(rng, image.Rect(0, 0, 45, 12))
+	tests := []struct {
+		name string
+		r    image.Rectangle
+		want image.Rectangle // the part of bm expected
+	}{
+		{"inside", image.Rect(3, 2, 30, 9), image.Rect(3, 2, 30, 9)},
+		{"right edge", image.Rect(37, 0, 45, 12), image.Rect(37, 0, 45, 12)},
+		{"overlapping", image.Rect(-5, 10, 50, 20), image.Rect(0, 10, 45, 12)},
+		{"outside", image.Rect(50, 0, 60, 5), image.Rectangle{}},
+	}
+	for _, tt := range tests {
+		got := bm.Crop(tt.r)
+		want := NewBitmap(image.Rect(0, 0, tt.want.Dx(), tt.want.Dy()))
+		for y := 0; y < tt.want.Dy(); y++ {
+			for x := 0; x < tt.want.Dx(); x++ {
+				want.SetBlack(x, y, bm.Black(tt.want.Min.X+x, tt.want.Min.Y+y))
+			}
+		}
+		checkBitmap(t, tt.name, got, want)
+	}
+}
+
+func TestBitmapTransforms(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	tests := []struct {
+		name  string
+		apply func(*Bitmap) *Bitmap
+		turns bool // width and height swap
+		src   func(w, h, x, y int) (int, int)
+	}{
+		{"Rotate90CW", (*Bitmap).Rotate90CW, true, func(w, h, x, y int) (int, int) { return y, h - 1 - x }},
+		{"Rotate90CCW", (*Bitmap).Rotate90CCW, true, func(w, h, x, y int) (int, int) { return w - 1 - y, x }},
+		{"Rotate180", (*Bitmap).Rotate180, false, func(w, h, x, y int) (int, int) { return w - 1 - x, h - 1 - y }},
+		{"FlipH", (*Bitmap).FlipH, false, func(w, h, x, y int) (int, int) { return w - 1 - x, y }},
+		{"FlipV", (*Bitmap).FlipV, false, func(w, h, x, y int) (int, int) { return x, h - 1 - y }},
+	}
+	sizes := []image.Rectangle{
+		image.Rect(0, 0, 16, 8),
+		image.Rect(0, 0, 13, 5),
+		image.Rect(2, 3, 87, 12),
+		image.Rect(0, 0, 1, 9),
+	}
+
+	for _, r := range sizes {
+		bm := randomBitmap(rng, r)
+		w, h := r.Dx(), r.Dy()
+		for _, tt := range tests {
+			ww, wh := w, h
+			if tt.turns {
+				ww, wh = h, w
+			}
+			want := NewBitmap(image.Rect(0, 0, ww, wh))
+			for y := 0; y < wh; y++ {
+				for x := 0; x < ww; x++ {
+					sx, sy := tt.src(w, h, x, y)
+					want.SetBlack(x, y, bm.Black(r.Min.X+sx, r.Min.Y+sy))
+				}
+			}
+			checkBitmap(t, tt.name, tt.apply(bm), want)
+		}
+	}
+
+	// Four quarter turns give the bitmap back
+	bm := randomBitmap(rng, image.Rect(0, 0, 19, 7))
+	checkBitmap(t, "four turns", bm.Rotate90CW().Rotate90CW().Rotate90CW().Rotate90CW(), bm)
+	checkBitmap(t, "turn and back", bm.Rotate90CW().Rotate90CCW(), bm)
+}
+
+func TestInvert(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	for _, w := range []int{8, 11, 85} {
+		bm := randomBitmap(rng, image.Rect(0, 0, w, 3))
+		want := NewBitmap(bm.Rect)
+		for y := 0; y < 3; y++ {
+			for x := 0; x < w; x++ {
+				want.SetBlack(x, y, !bm.Black(x, y))
+			}
+		}
+		bm.Invert()
+		checkBitmap(t, "invert", bm, want)
+	}
+}
@@ -0,0 +1,135 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/vector"
+)
+
+// TextBox is a box drawn around the text, inside the margins
+type TextBox struct {
+	Width   int // line width in dots, 0 for 2
+	Radius  int // corner radius in dots, 0 for square corners
+	Padding int // space between the text and the line in dots
+}
+
+// lineWidth returns the box's line width in dots
+func (b TextBox) lineWidth() int {
+	if b.Width <= 0 {
+		return 2
+	}
+	return b.Width
+}
+
+// effectInset is the room the box and outline need around the text
+func (opts TextOptions) effectInset() int {
+	inset := max(opts.Outline, 0)
+	if opts.Box != nil {
+		inset += opts.Box.Padding + opts.Box.lineWidth()
+	}
+	return inset
+}
+
+// highlightPad is how far a highlight bar reaches either side of its line
+func highlightPad(m font.Metrics) int {
+	return max(2, m.Ascent.Ceil()/6)
+}
+
+// dilate returns a mask with every pixel set to the strongest pixel within
+// radius of it
+func dilate(mask *image.Alpha, radius int) *image.Alpha {
+	b := mask.Bounds()
+	out := image.NewAlpha(b)
+	r2 := radius * radius
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			if dx*dx+dy*dy > r2 {
+				continue
+			}
+			for y := max(b.Min.Y, b.Min.Y-dy); y < min(b.Max.Y, b.Max.Y-dy); y++ {
+				src := mask.Pix[mask.PixOffset(b.Min.X, y+dy):]
+				dst := out.Pix[out.PixOffset(b.Min.X, y):]
+				for x := max(0, -dx); x < min(b.Dx(), b.Dx()-dx); x++ {
+					dst[x] = max(dst[x], src[x+dx])
+				}
+			}
+		}
+	}
+	return out
+}
+
+// outlineMask hollows out text: it keeps a ring width pixels wide around
+// the outside of the shapes in mask
+func outlineMask(mask *image.Alpha, width int) *image.Alpha {
+	ring := dilate(mask, width)
+	for i, a := range mask.Pix {
+		ring.Pix[i] -= min(ring.Pix[i], a)
+	}
+	return ring
+}
+
+// drawBox draws the outline of r, width pixels wide and with corners
+// rounded to radius, inside r
+func drawBox(dst draw.Image, src image.Image, r image.Rectangle, width, radius int) {
+	if r.Dx() <= 0 || r.Dy() <= 0 {
+		return
+	}
+	width = min(width, r.Dx()/2, r.Dy()/2)
+	radius = min(radius, r.Dx()/2, r.Dy()/2)
+
+	var rast vector.Rasterizer
+	rast.Reset(r.Dx(), r.Dy())
+	outer := [4]float32{0, 0, float32(r.Dx()), float32(r.Dy())}
+	w := float32(width)
+	inner := [4]float32{w, w, outer[2] - w, outer[3] - w}
+	roundedRect(&rast, outer, float32(radius), false)
+	roundedRect(&rast, inner, float32(max(radius-width, 0)), true)
+
+	mask := image.NewAlpha(image.Rect(0, 0, r.Dx(), r.Dy()))
+	rast.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	draw.DrawMask(dst, r, src, image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// roundedRect adds a rectangle (x0, y0, x1, y1) with rounded corners to a
+// path, clockwise on screen or, for a hole, counter-clockwise
+func roundedRect(rast *vector.Rasterizer, rect [4]float32, radius float32, hole bool) {
+	x0, y0, x1, y1 := rect[0], rect[1], rect[2], rect[3]
+	if x1 <= x0 || y1 <= y0 {
+		return
+	}
+
+	// Corners as cubic Béziers: the control points sit k*radius along the
+	// tangents, which is within 0.03% of a circle
+	const k = 0.5523
+	c := radius * (1 - k)
+	type corner struct{ x, y, cx1, cy1, cx2, cy2, ex, ey float32 }
+	corners := []corner{
+		// start of the straight edge, control points, end of the corner
+		{x1 - radius, y0, x1 - c, y0, x1, y0 + c, x1, y0 + radius},
+		{x1, y1 - radius, x1, y1 - c, x1 - c, y1, x1 - radius, y1},
+		{x0 + radius, y1, x0 + c, y1, x0, y1 - c, x0, y1 - radius},
+		{x0, y0 + radius, x0, y0 + c, x0 + c, y0, x0 + radius, y0},
+	}
+
+	if hole {
+		// The same corners traversed backwards
+		rast.MoveTo(x0+radius, y0)
+		for i := len(corners) - 1; i >= 0; i-- {
+			cr := corners[i]
+			rast.CubeTo(cr.cx2, cr.cy2, cr.cx1, cr.cy1, cr.x, cr.y)
+			prev := corners[(i+len(corners)-1)%len(corners)]
+			rast.LineTo(prev.ex, prev.ey)
+		}
+		rast.ClosePath()
+		return
+	}
+
+	rast.MoveTo(x0+radius, y0)
+	for _, cr := range corners {
+		rast.LineTo(cr.x, cr.y)
+		rast.CubeTo(cr.cx1, cr.cy1, cr.cx2, cr.cy2, cr.ex, cr.ey)
+	}
+	rast.ClosePath()
+}
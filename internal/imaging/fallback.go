@@ -0,0 +1,101 @@
+package imaging
+
+import "golang.org/x/image/font"
+
+// FallbackFamilies are tried in order for characters the chosen font lacks
+// Families that are not installed are skipped
+var FallbackFamilies = []string{
+	"Go",
+	"DejaVu Sans",
+	"Noto Sans",
+	"Noto Sans Symbols",
+	"Noto Sans Symbols 2",
+	"Noto Sans CJK JP",
+	"Noto Sans JP",
+	"Noto Sans Arabic",
+	"Noto Sans Hebrew",
+	"Noto Sans Devanagari",
+	"Noto Sans Thai",
+	"Droid Sans Fallback",
+	"WenQuanYi Zen Hei",
+	"Segoe UI",
+	"Segoe UI Symbol",
+	"Yu Gothic",
+	"MS Gothic",
+	"Malgun Gothic",
+	"Microsoft YaHei",
+	"Arial Unicode MS",
+}
+
+// MissingGlyphs returns the characters of text that no font in the fallback
+// chain can draw, each listed once
+// It reuses the registry's cached chains and faces, so checking text just
+// rendered with the same options loads nothing new
+func MissingGlyphs(text string, opts TextOptions) ([]rune, error) {
+	base, err := newGlyphCover(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each style has its own fonts, so bold text may lack what regular has
+	covers := map[shaperKey]glyphCover{{size: 1}: base}
+	styled := newStyledText(opts.spans(text))
+	var missing []rune
+	seen := make(map[rune]bool)
+	for i, r := range styled.text {
+		st := styled.styles[i]
+		if seen[r] || IsWhitespace(r) || st.Icon != "" {
+			continue
+		}
+
+		key := shaperKey{bold: st.Bold, italic: st.Italic, size: st.scale()}
+		cover, ok := covers[key]
+		if !ok {
+			o := opts
+			o.Bold = o.Bold || st.Bold
+			o.Italic = o.Italic || st.Italic
+			o.FontSize *= key.size
+			if cover, err = newGlyphCover(o); err != nil {
+				cover = base
+			}
+			covers[key] = cover
+		}
+
+		if !cover.has(r) {
+			seen[r] = true
+			missing = append(missing, r)
+		}
+	}
+	return missing, nil
+}
+
+// glyphCover is the fonts of a fallback chain, for checking which
+// characters they can draw
+type glyphCover []font.Face
+
+// newGlyphCover loads the options' fallback chain the way newTextShaper
+// does: broken fallback fonts are skipped, but the chosen font must load
+func newGlyphCover(opts TextOptions) (glyphCover, error) {
+	var cover glyphCover
+	for i, entry := range opts.fontChain() {
+		face, err := Fonts.Face(entry.name, opts.FontSize, textDPI, font.HintingNone)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			continue
+		}
+		cover = append(cover, face)
+	}
+	return cover, nil
+}
+
+// has reports whether any font in the chain can draw r
+func (c glyphCover) has(r rune) bool {
+	for _, face := range c {
+		if _, ok := face.GlyphAdvance(r); ok {
+			return true
+		}
+	}
+	return false
+}
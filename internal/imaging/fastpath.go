@@ -0,0 +1,97 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// rgbaReader reads rows of an image as 8-bit premultiplied RGBA, the values
+// image.RGBA.Set would store for img.At(x, y)
+// Common image types are read straight from their pixel buffers instead of
+// going through the color.Color interface for every pixel
+type rgbaReader struct {
+	img     image.Image
+	palette []color.RGBA // converted palette of a paletted image
+}
+
+// newRGBAReader returns a reader for img
+func newRGBAReader(img image.Image) *rgbaReader {
+	r := &rgbaReader{img: img}
+	if p, ok := img.(*image.Paletted); ok {
+		r.palette = make([]color.RGBA, len(p.Palette))
+		for i, c := range p.Palette {
+			r.palette[i] = color.RGBAModel.Convert(c).(color.RGBA)
+		}
+	}
+	return r
+}
+
+// row reads len(row) pixels of row y, starting at the image's left edge
+func (r *rgbaReader) row(y int, row []color.RGBA) {
+	x0 := r.img.Bounds().Min.X
+
+	switch src := r.img.(type) {
+	case *image.RGBA:
+		pix := src.Pix[src.PixOffset(x0, y):]
+		for x := range row {
+			s := pix[x*4 : x*4+4 : x*4+4]
+			row[x] = color.RGBA{s[0], s[1], s[2], s[3]}
+		}
+	case *image.NRGBA:
+		pix := src.Pix[src.PixOffset(x0, y):]
+		for x := range row {
+			s := pix[x*4 : x*4+4 : x*4+4]
+			cr, cg, cb, ca := color.NRGBA{s[0], s[1], s[2], s[3]}.RGBA()
+			row[x] = color.RGBA{uint8(cr >> 8), uint8(cg >> 8), uint8(cb >> 8), uint8(ca >> 8)}
+		}
+	case *image.Gray:
+		pix := src.Pix[src.PixOffset(x0, y):]
+		for x := range row {
+			v := pix[x]
+			row[x] = color.RGBA{v, v, v, 0xFF}
+		}
+	case *image.YCbCr:
+		yi := src.YOffset(x0, y)
+		for x := range row {
+			ci := src.COffset(x0+x, y)
+			cr, cg, cb, _ := color.YCbCr{src.Y[yi+x], src.Cb[ci], src.Cr[ci]}.RGBA()
+			row[x] = color.RGBA{uint8(cr >> 8), uint8(cg >> 8), uint8(cb >> 8), 0xFF}
+		}
+	case *image.Paletted:
+		pix := src.Pix[src.PixOffset(x0, y):]
+		for x := range row {
+			if idx := int(pix[x]); idx < len(r.palette) {
+				row[x] = r.palette[idx]
+			} else {
+				row[x] = color.RGBA{}
+			}
+		}
+	default:
+		for x := range row {
+			row[x] = color.RGBAModel.Convert(src.At(x0+x, y)).(color.RGBA)
+		}
+	}
+}
+
+// copyRGBA writes every pixel of src into dst; the pixel at (x, y) from the
+// top-left corner of src goes to origin + x*dx + y*dy
+func copyRGBA(dst *image.RGBA, src image.Image, origin, dx, dy image.Point) {
+	bounds := src.Bounds()
+	reader := newRGBAReader(src)
+	row := make([]color.RGBA, bounds.Dx())
+
+	// Offsets in dst.Pix for a step along a source row and down a column
+	colStep := dx.X*4 + dx.Y*dst.Stride
+	rowStep := dy.X*4 + dy.Y*dst.Stride
+	start := dst.PixOffset(origin.X, origin.Y)
+
+	for y := 0; y < bounds.Dy(); y++ {
+		reader.row(bounds.Min.Y+y, row)
+		i := start + y*rowStep
+		for _, c := range row {
+			s := dst.Pix[i : i+4 : i+4]
+			s[0], s[1], s[2], s[3] = c.R, c.G, c.B, c.A
+			i += colStep
+		}
+	}
+}
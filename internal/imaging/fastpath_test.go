@@ -0,0 +1,156 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+// genericImage hides an image's type so it is read through At
+type genericImage struct {
+	image.Image
+}
+
+// imageTypes names the images testImages returns, in a fixed order
+var imageTypes = []string{"RGBA", "NRGBA", "Gray", "YCbCr", "Paletted"}
+
+// testImages returns images of each type with a fast path, filled with the
+// same random pixels, with bounds r
+func testImages(r image.Rectangle) map[string]image.Image {
+	rng := rand.New(rand.NewSource(5))
+	nrgba := image.NewNRGBA(r)
+	rng.Read(nrgba.Pix)
+
+	rgba := image.NewRGBA(r)
+	draw.Draw(rgba, r, nrgba, r.Min, draw.Src)
+
+	gray := image.NewGray(r)
+	rng.Read(gray.Pix)
+
+	ycbcr := image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+	rng.Read(ycbcr.Y)
+	rng.Read(ycbcr.Cb)
+	rng.Read(ycbcr.Cr)
+
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		palette[i] = color.NRGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256))}
+	}
+	paletted := image.NewPaletted(r, palette)
+	rng.Read(paletted.Pix)
+
+	return map[string]image.Image{
+		"RGBA":     rgba,
+		"NRGBA":    nrgba,
+		"Gray":     gray,
+		"YCbCr":    ycbcr,
+		"Paletted": paletted,
+	}
+}
+
+func TestRGBAReaderMatchesAt(t *testing.T) {
+	// Odd bounds away from the origin catch offset and subsampling mistakes
+	r := image.Rect(-3, 5, 38, 22)
+	for name, img := range testImages(r) {
+		reader := newRGBAReader(img)
+		row := make([]color.RGBA, r.Dx())
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			reader.row(y, row)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				want := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+				if got := row[x-r.Min.X]; got != want {
+					t.Fatalf("%s: pixel (%d, %d) is %v, want %v", name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestFastPathsMatchGeneric(t *testing.T) {
+	r := image.Rect(3, 1, 70, 45)
+	for name, img := range testImages(r) {
+		generic := genericImage{img}
+
+		for _, size := range []image.Point{{96, 96}, {40, 200}, {13, 7}} {
+			got := ToBitmap(img, size.X, size.Y, 128)
+			want := ToBitmap(generic, size.X, size.Y, 128)
+			if !bytes.Equal(got.Pix, want.Pix) {
+				t.Errorf("%s: ToBitmap %v differs from the generic path", name, size)
+			}
+		}
+
+		transforms := []struct {
+			name  string
+			apply func(image.Image) image.Image
+		}{
+			{"rotate90CW", rotate90CW},
+			{"rotate90CCW", rotate90CCW},
+			{"rotate180", rotate180},
+			{"mirror horizontal", func(img image.Image) image.Image { return mirror(img, true) }},
+			{"mirror vertical", func(img image.Image) image.Image { return mirror(img, false) }},
+		}
+		for _, tr := range transforms {
+			got := tr.apply(img).(*image.RGBA)
+			want := tr.apply(generic).(*image.RGBA)
+			if !bytes.Equal(got.Pix, want.Pix) {
+				t.Errorf("%s: %s differs from the generic path", name, tr.name)
+			}
+		}
+	}
+}
+
+func TestRotate90CW(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(src.Pix, []byte{1, 2, 3, 4, 5, 6})
+	dst := rotate90CW(src).(*image.RGBA)
+	if dst.Rect != image.Rect(0, 0, 2, 3) {
+		t.Fatalf("bounds %v, want 2x3", dst.Rect)
+	}
+	// The left column becomes the top row, read bottom to top
+	want := [][]byte{{4, 1}, {5, 2}, {6, 3}}
+	for y, row := range want {
+		for x, v := range row {
+			if got := dst.RGBAAt(x, y).R; got != v {
+				t.Errorf("(%d, %d) = %d, want %d", x, y, got, v)
+			}
+		}
+	}
+}
+
+func BenchmarkToBitmap(b *testing.B) {
+	images := testImages(image.Rect(0, 0, 1200, 900))
+	for _, name := range imageTypes {
+		img := images[name]
+		for _, src := range []struct {
+			kind string
+			img  image.Image
+		}{{"fast", img}, {"generic", genericImage{img}}} {
+			b.Run(fmt.Sprintf("%s/%s", name, src.kind), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					ToBitmap(src.img, 384, 240, 128)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkRotate90CW(b *testing.B) {
+	images := testImages(image.Rect(0, 0, 640, 480))
+	for _, name := range imageTypes {
+		img := images[name]
+		for _, src := range []struct {
+			kind string
+			img  image.Image
+		}{{"fast", img}, {"generic", genericImage{img}}} {
+			b.Run(fmt.Sprintf("%s/%s", name, src.kind), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					rotate90CW(src.img)
+				}
+			})
+		}
+	}
+}
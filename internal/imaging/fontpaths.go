@@ -0,0 +1,25 @@
+package imaging
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// UserFontDir is where users can drop extra fonts for labels
+// (e.g. ~/.config/nelko-print/fonts), or "" if there is no config directory
+func UserFontDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "nelko-print", "fonts")
+}
+
+// FontDirs lists the directories searched for fonts, the user font directory first
+func FontDirs() []string {
+	var dirs []string
+	if dir := UserFontDir(); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return append(dirs, systemFontDirs()...)
+}
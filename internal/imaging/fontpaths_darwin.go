@@ -0,0 +1,22 @@
+//go:build darwin
+
+package imaging
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// systemFontDirs returns the user, local and system font folders
+func systemFontDirs() []string {
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "Library", "Fonts"))
+	}
+	return append(dirs, "/Library/Fonts", "/System/Library/Fonts", "/System/Library/Fonts/Supplemental")
+}
+
+// systemHyphenDirs returns the dictionaries bundled with LibreOffice
+func systemHyphenDirs() []string {
+	return []string{"/Applications/LibreOffice.app/Contents/Resources/extensions"}
+}
@@ -0,0 +1,49 @@
+//go:build linux
+
+package imaging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// systemFontDirs returns the font directories fontconfig searches by default:
+// the user's XDG data dir and ~/.fonts, then each XDG data dir
+func systemFontDirs() []string {
+	var dirs []string
+
+	home, _ := os.UserHomeDir()
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" && home != "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	if dataHome != "" {
+		dirs = append(dirs, filepath.Join(dataHome, "fonts"))
+	}
+	if home != "" {
+		dirs = append(dirs, filepath.Join(home, ".fonts"))
+	}
+
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	for _, d := range strings.Split(dataDirs, ":") {
+		if d != "" {
+			dirs = append(dirs, filepath.Join(d, "fonts"))
+		}
+	}
+	return dirs
+}
+
+// systemHyphenDirs returns where distributions install hyphenation
+// dictionaries (the hyphen-* packages) and LibreOffice's bundled ones
+func systemHyphenDirs() []string {
+	return []string{
+		"/usr/share/hyphen",
+		"/usr/share/myspell/dicts",
+		"/usr/lib/libreoffice/share/extensions",
+		"/opt/libreoffice/share/extensions",
+	}
+}
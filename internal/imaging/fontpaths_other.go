@@ -0,0 +1,14 @@
+//go:build !linux && !windows && !darwin
+
+package imaging
+
+// systemFontDirs returns no directories; only the user font directory and
+// the embedded fonts are used
+func systemFontDirs() []string {
+	return nil
+}
+
+// systemHyphenDirs returns no directories
+func systemHyphenDirs() []string {
+	return nil
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package imaging
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// systemFontDirs returns %WINDIR%\Fonts and the per-user font directory
+func systemFontDirs() []string {
+	var dirs []string
+	windir := os.Getenv("WINDIR")
+	if windir == "" {
+		windir = `C:\Windows`
+	}
+	dirs = append(dirs, filepath.Join(windir, "Fonts"))
+	if local := os.Getenv("LOCALAPPDATA"); local != "" {
+		dirs = append(dirs, filepath.Join(local, "Microsoft", "Windows", "Fonts"))
+	}
+	return dirs
+}
+
+// systemHyphenDirs returns the dictionaries bundled with LibreOffice
+func systemHyphenDirs() []string {
+	var dirs []string
+	for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+		if dir := os.Getenv(env); dir != "" {
+			dirs = append(dirs, filepath.Join(dir, "LibreOffice", "share", "extensions"))
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,472 @@
+package imaging
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	gotext "github.com/go-text/typesetting/font"
+	tfont "github.com/go-text/typesetting/opentype/api/font"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// DefaultFont is the embedded font used when no font is chosen
+const DefaultFont = "Go Regular"
+
+// textDPI matches the printer resolution so font sizes are in printer points
+const textDPI = 203
+
+// FontInfo describes a font known to the registry
+type FontInfo struct {
+	Name   string // "Family Style", the key fonts are looked up by
+	Family string
+	Style  string
+	Path   string // file the font was found in, "" for embedded fonts
+}
+
+// fontSource is where a registered font's data comes from
+type fontSource struct {
+	info  FontInfo
+	data  []byte // embedded data, nil to read Path on first use
+	index int    // font index within a collection file
+}
+
+// faceKey identifies a font face in the registry cache
+type faceKey struct {
+	font    string
+	size    float64
+	dpi     float64
+	hinting font.Hinting
+}
+
+// maxCachedFaces caps the face cache; fitting text to a label tries many
+// sizes, each of which would otherwise keep a face and its glyph cache
+const maxCachedFaces = 64
+
+// cachedFace is an entry of the registry's face cache
+type cachedFace struct {
+	key  faceKey
+	face font.Face
+}
+
+// chainKey identifies a fallback chain by the options it was resolved for
+type chainKey struct {
+	family, style string
+	bold, italic  bool
+}
+
+// FontRegistry parses each font once and caches faces by font, size, DPI and
+// hinting, dropping the least recently used faces beyond maxCachedFaces. It
+// also caches fallback chains until another font is registered. It is safe
+// for concurrent use
+type FontRegistry struct {
+	mu      sync.Mutex
+	sources map[string]fontSource
+	fonts   map[string]*opentype.Font
+	data    map[string][]byte      // file contents of the parsed fonts
+	shapers map[string]*tfont.Font // the same fonts parsed for shaping
+	faces   map[faceKey]*list.Element
+	faceLRU *list.List // cachedFace entries, most recently used first
+	chains  map[chainKey][]chainEntry
+	gen     int // counts registrations, so a chain resolved meanwhile is not cached
+}
+
+// NewFontRegistry returns an empty registry
+func NewFontRegistry() *FontRegistry {
+	return &FontRegistry{
+		sources: make(map[string]fontSource),
+		fonts:   make(map[string]*opentype.Font),
+		data:    make(map[string][]byte),
+		shapers: make(map[string]*tfont.Font),
+		faces:   make(map[faceKey]*list.Element),
+		faceLRU: list.New(),
+		chains:  make(map[chainKey][]chainEntry),
+	}
+}
+
+// Fonts is the registry text rendering uses
+var Fonts = newDefaultRegistry()
+
+func newDefaultRegistry() *FontRegistry {
+	r := NewFontRegistry()
+	r.RegisterFont(FontInfo{Name: DefaultFont, Family: "Go", Style: "Regular"}, goregular.TTF)
+	registerGoFonts(r)
+	return r
+}
+
+// Register adds font data under a name; it is parsed on first use
+// Registering a name again replaces the font and drops its cached faces
+func (r *FontRegistry) Register(name string, data []byte) {
+	r.RegisterFont(FontInfo{Name: name, Family: name}, data)
+}
+
+// RegisterFont adds font data with its family and style
+func (r *FontRegistry) RegisterFont(info FontInfo, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(fontSource{info: info, data: data})
+}
+
+func (r *FontRegistry) add(src fontSource) {
+	name := src.info.Name
+	r.sources[name] = src
+	delete(r.fonts, name)
+	delete(r.data, name)
+	delete(r.shapers, name)
+	for k, e := range r.faces {
+		if k.font == name {
+			r.faceLRU.Remove(e)
+			delete(r.faces, k)
+		}
+	}
+	// Any family may now resolve to a different font
+	clear(r.chains)
+	r.gen++
+}
+
+// cachedChain returns the fallback chain cached for key, and the generation
+// to pass to cacheChain if there is none
+func (r *FontRegistry) cachedChain(key chainKey) ([]chainEntry, int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chain, ok := r.chains[key]
+	return chain, r.gen, ok
+}
+
+// cacheChain caches a chain resolved at generation gen, unless fonts have
+// been registered since
+func (r *FontRegistry) cacheChain(key chainKey, gen int, chain []chainEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if gen == r.gen {
+		r.chains[key] = chain
+	}
+}
+
+// Font returns the parsed font registered under name
+func (r *FontRegistry) Font(name string) (*opentype.Font, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.font(name)
+}
+
+func (r *FontRegistry) font(name string) (*opentype.Font, error) {
+	if f, ok := r.fonts[name]; ok {
+		return f, nil
+	}
+	src, ok := r.sources[name]
+	if !ok {
+		return nil, fmt.Errorf("font %q not found", name)
+	}
+
+	data := src.data
+	if data == nil {
+		var err error
+		if data, err = os.ReadFile(src.info.Path); err != nil {
+			return nil, fmt.Errorf("font %q: %w", name, err)
+		}
+	}
+	f, err := parseFontAt(data, src.index)
+	if err != nil {
+		return nil, fmt.Errorf("font %q: %w", name, err)
+	}
+	r.fonts[name] = f
+	r.data[name] = data
+	return f, nil
+}
+
+// ShapingFont returns the font registered under name parsed for text shaping
+// Glyph IDs from shaping index the font returned by Font for the same name
+func (r *FontRegistry) ShapingFont(name string) (*tfont.Font, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.shapers[name]; ok {
+		return f, nil
+	}
+	if _, err := r.font(name); err != nil {
+		return nil, err
+	}
+
+	faces, err := gotext.ParseTTC(bytes.NewReader(r.data[name]))
+	if err != nil {
+		return nil, fmt.Errorf("font %q: %w", name, err)
+	}
+	index := r.sources[name].index
+	if index >= len(faces) {
+		return nil, fmt.Errorf("font %q: no font %d in collection", name, index)
+	}
+	f := faces[index].Font
+	r.shapers[name] = f
+	return f, nil
+}
+
+// parseFontAt parses a single font file or the index'th font of a collection
+func parseFontAt(data []byte, index int) (*opentype.Font, error) {
+	c, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, err
+	}
+	return c.Font(index)
+}
+
+// Face returns a face for the named font at size points and dpi
+// Faces are shared, so they are safe for concurrent use
+func (r *FontRegistry) Face(name string, size, dpi float64, hinting font.Hinting) (font.Face, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := faceKey{font: name, size: size, dpi: dpi, hinting: hinting}
+	if e, ok := r.faces[key]; ok {
+		r.faceLRU.MoveToFront(e)
+		return e.Value.(*cachedFace).face, nil
+	}
+
+	f, err := r.font(name)
+	if err != nil {
+		return nil, err
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     dpi,
+		Hinting: hinting,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("font %q: %w", name, err)
+	}
+	locked := &lockedFace{face: face}
+	r.faces[key] = r.faceLRU.PushFront(&cachedFace{key: key, face: locked})
+	// Faces already handed out stay usable after they are dropped
+	if r.faceLRU.Len() > maxCachedFaces {
+		oldest := r.faceLRU.Remove(r.faceLRU.Back()).(*cachedFace)
+		delete(r.faces, oldest.key)
+	}
+	return locked, nil
+}
+
+// regularStyles are the names fonts use for their upright normal-weight style,
+// in order of preference
+var regularStyles = []string{"Regular", "Book", "Normal", "Roman", "Medium"}
+
+// Lookup returns the name of the font with the given family and style
+// If the style is empty or missing, the family's regular style is used, or
+// failing that its first font
+func (r *FontRegistry) Lookup(family, style string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byStyle := make(map[string]string)
+	var first string
+	for name, src := range r.sources {
+		if !strings.EqualFold(src.info.Family, family) {
+			continue
+		}
+		byStyle[strings.ToLower(src.info.Style)] = name
+		if first == "" || name < first {
+			first = name
+		}
+	}
+
+	if name, ok := byStyle[strings.ToLower(style)]; ok && style != "" {
+		return name, true
+	}
+	for _, s := range regularStyles {
+		if name, ok := byStyle[strings.ToLower(s)]; ok {
+			return name, true
+		}
+	}
+	return first, first != ""
+}
+
+// Info returns the details of the font registered under name
+func (r *FontRegistry) Info(name string) (FontInfo, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	src, ok := r.sources[name]
+	return src.info, ok
+}
+
+// Families returns the sorted font family names
+func (r *FontRegistry) Families() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var families []string
+	for _, src := range r.sources {
+		if !seen[src.info.Family] {
+			seen[src.info.Family] = true
+			families = append(families, src.info.Family)
+		}
+	}
+	sort.Strings(families)
+	return families
+}
+
+// Styles returns the sorted style names available for a family
+func (r *FontRegistry) Styles(family string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var styles []string
+	for _, src := range r.sources {
+		if src.info.Family == family {
+			styles = append(styles, src.info.Style)
+		}
+	}
+	sort.Strings(styles)
+	return styles
+}
+
+// ScanDirs registers the TrueType and OpenType fonts found under dirs
+// Missing directories are skipped; fonts that fail to parse are ignored.
+// Fonts already registered under the same name are kept, so earlier
+// directories win
+func (r *FontRegistry) ScanDirs(dirs ...string) int {
+	found := 0
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !isFontFile(path) {
+				return nil
+			}
+			for _, src := range scanFontFile(path) {
+				r.mu.Lock()
+				if _, ok := r.sources[src.info.Name]; !ok {
+					r.add(src)
+					found++
+				}
+				r.mu.Unlock()
+			}
+			return nil
+		})
+	}
+	return found
+}
+
+// isFontFile reports whether path has a font file extension
+func isFontFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ttf", ".otf", ".ttc", ".otc":
+		return true
+	}
+	return false
+}
+
+// scanFontFile reads the family and style of every font in a file
+// The font data is not kept; it is read again when the font is used
+func scanFontFile(path string) []fontSource {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	c, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil
+	}
+
+	var sources []fontSource
+	var buf sfnt.Buffer
+	for i := 0; i < c.NumFonts(); i++ {
+		f, err := c.Font(i)
+		if err != nil {
+			continue
+		}
+		family := fontName(f, &buf, sfnt.NameIDTypographicFamily, sfnt.NameIDFamily)
+		style := fontName(f, &buf, sfnt.NameIDTypographicSubfamily, sfnt.NameIDSubfamily)
+		if family == "" {
+			continue
+		}
+		if style == "" {
+			style = "Regular"
+		}
+		sources = append(sources, fontSource{
+			info: FontInfo{
+				Name:   family + " " + style,
+				Family: family,
+				Style:  style,
+				Path:   path,
+			},
+			index: i,
+		})
+	}
+	return sources
+}
+
+// fontName returns the first of the name table entries the font has
+func fontName(f *sfnt.Font, buf *sfnt.Buffer, ids ...sfnt.NameID) string {
+	for _, id := range ids {
+		if name, err := f.Name(buf, id); err == nil && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// lockedFace serialises access to a face, whose glyph cache is not safe for
+// concurrent use
+type lockedFace struct {
+	mu   sync.Mutex
+	face font.Face
+}
+
+func (f *lockedFace) Close() error {
+	return nil // shared faces live as long as the registry
+}
+
+func (f *lockedFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dr, mask, maskp, adv, ok := f.face.Glyph(dot, r)
+	if !ok {
+		return dr, mask, maskp, adv, ok
+	}
+	// The mask is the face's reusable buffer; copy it before unlocking
+	return dr, copyMask(mask), maskp, adv, ok
+}
+
+func (f *lockedFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.GlyphBounds(r)
+}
+
+func (f *lockedFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.GlyphAdvance(r)
+}
+
+func (f *lockedFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.Kern(r0, r1)
+}
+
+func (f *lockedFace) Metrics() font.Metrics {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.Metrics()
+}
+
+// copyMask copies a glyph mask so it outlives the face's next Glyph call
+func copyMask(mask image.Image) image.Image {
+	if a, ok := mask.(*image.Alpha); ok {
+		c := *a
+		c.Pix = append([]uint8(nil), a.Pix...)
+		return &c
+	}
+	return mask
+}
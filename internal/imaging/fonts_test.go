@@ -0,0 +1,58 @@
+package imaging
+
+import (
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestFaceCacheLimit(t *testing.T) {
+	r := NewFontRegistry()
+	r.Register("Test", goregular.TTF)
+
+	first, err := r.Face("Test", 1, textDPI, font.HintingNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 2; i <= maxCachedFaces+10; i++ {
+		if _, err := r.Face("Test", float64(i), textDPI, font.HintingNone); err != nil {
+			t.Fatal(err)
+		}
+		// Using the first face keeps it cached
+		if i%8 == 0 {
+			if again, _ := r.Face("Test", 1, textDPI, font.HintingNone); again != first {
+				t.Fatalf("face used recently was dropped after %d faces", i)
+			}
+		}
+	}
+	if n := len(r.faces); n != maxCachedFaces || r.faceLRU.Len() != n {
+		t.Errorf("%d faces cached (list %d), want %d", n, r.faceLRU.Len(), maxCachedFaces)
+	}
+	if _, ok := r.faces[faceKey{font: "Test", size: 2, dpi: textDPI}]; ok {
+		t.Error("least recently used face was kept")
+	}
+
+	// Replacing the font drops its faces
+	r.Register("Test", goregular.TTF)
+	if len(r.faces) != 0 || r.faceLRU.Len() != 0 {
+		t.Errorf("%d faces left after replacing the font", len(r.faces))
+	}
+}
+
+func TestFontChainCache(t *testing.T) {
+	opts := TextOptions{FontFamily: "Chain Test", FontSize: 12}
+	chain := opts.fontChain()
+	if len(chain) == 0 || chain[0].name != DefaultFont {
+		t.Fatalf("chain for a missing family starts with %v, want %q", chain, DefaultFont)
+	}
+	if again := opts.fontChain(); &again[0] != &chain[0] {
+		t.Error("chain was resolved again")
+	}
+
+	// Registering the family must not leave the old chain cached
+	Fonts.RegisterFont(FontInfo{Name: "Chain Test Regular", Family: "Chain Test", Style: "Regular"}, goregular.TTF)
+	if chain := opts.fontChain(); chain[0].name != "Chain Test Regular" {
+		t.Errorf("chain starts with %q after registering the family", chain[0].name)
+	}
+}
@@ -0,0 +1,289 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// Hyphenator finds where words may be hyphenated with Liang's algorithm,
+// using the same patterns as TeX and LibreOffice
+type Hyphenator struct {
+	patterns map[string][]byte // pattern letters to the values around them
+	maxLen   int               // longest pattern, in runes
+	leftMin  int               // fewest letters before a hyphen
+	rightMin int               // fewest letters after a hyphen
+}
+
+// ParseHyphenPatterns reads hyphenation patterns: a LibreOffice/hunspell
+// .dic file (its first line names the encoding) if dic is set, otherwise
+// UTF-8 patterns as in the hyph-utf8 .pat.txt files
+func ParseHyphenPatterns(data []byte, dic bool) (*Hyphenator, error) {
+	h := &Hyphenator{patterns: make(map[string][]byte), leftMin: 2, rightMin: 2}
+
+	if dic {
+		header, rest, _ := bytes.Cut(data, []byte("\n"))
+		data = rest
+		name := strings.TrimSpace(string(header))
+		if !strings.EqualFold(name, "UTF-8") {
+			enc, err := ianaindex.IANA.Encoding(strings.Replace(name, "ISO8859", "ISO-8859", 1))
+			if err != nil || enc == nil {
+				return nil, fmt.Errorf("unsupported hyphenation encoding %q", name)
+			}
+			if data, err = enc.NewDecoder().Bytes(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "%") {
+			continue
+		}
+		switch fields[0] {
+		case "LEFTHYPHENMIN", "RIGHTHYPHENMIN":
+			if len(fields) > 1 {
+				if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+					if fields[0] == "LEFTHYPHENMIN" {
+						h.leftMin = n
+					} else {
+						h.rightMin = n
+					}
+				}
+			}
+			continue
+		case "NEXTLEVEL", "NOHYPHEN", "COMPOUNDLEFTHYPHENMIN", "COMPOUNDRIGHTHYPHENMIN":
+			continue
+		}
+		for _, p := range fields {
+			h.addPattern(p)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(h.patterns) == 0 {
+		return nil, fmt.Errorf("no hyphenation patterns found")
+	}
+	return h, nil
+}
+
+// addPattern adds a pattern such as ".ab4c1d"; non-standard patterns (with
+// a replacement after '/') are skipped
+func (h *Hyphenator) addPattern(p string) {
+	if strings.ContainsRune(p, '/') {
+		return
+	}
+	var letters []rune
+	values := []byte{0}
+	for _, r := range p {
+		if r >= '0' && r <= '9' {
+			values[len(values)-1] = byte(r - '0')
+			continue
+		}
+		letters = append(letters, unicode.ToLower(r))
+		values = append(values, 0)
+	}
+	if len(letters) == 0 {
+		return
+	}
+	h.patterns[string(letters)] = values
+	h.maxLen = max(h.maxLen, len(letters))
+}
+
+// Hyphenate returns the rune offsets in word where a hyphen may go
+func (h *Hyphenator) Hyphenate(word string) []int {
+	// Lower-case rune by rune: strings.ToLower can change the number of
+	// runes (İ becomes i and a combining dot), shifting the offsets
+	letters := []rune(word)
+	for i, r := range letters {
+		letters[i] = unicode.ToLower(r)
+	}
+	n := len(letters)
+	if n < h.leftMin+h.rightMin {
+		return nil
+	}
+
+	// values[i] is the value between letters i-1 and i of ".word."
+	dotted := append(append([]rune{'.'}, letters...), '.')
+	values := make([]byte, len(dotted)+1)
+	for i := range dotted {
+		for l := 1; l <= h.maxLen && i+l <= len(dotted); l++ {
+			p, ok := h.patterns[string(dotted[i:i+l])]
+			if !ok {
+				continue
+			}
+			for k, v := range p {
+				values[i+k] = max(values[i+k], v)
+			}
+		}
+	}
+
+	// Odd values are hyphenation points; offset k in word is k+1 in dotted
+	var points []int
+	for k := h.leftMin; k <= n-h.rightMin; k++ {
+		if values[k+1]%2 == 1 {
+			points = append(points, k)
+		}
+	}
+	return points
+}
+
+// HyphenRegistry finds hyphenation dictionaries by language and loads them
+// when first used
+// It is safe for concurrent use
+type HyphenRegistry struct {
+	mu     sync.Mutex
+	paths  map[string]string // language (e.g. "de_DE") to file
+	loaded map[string]*Hyphenator
+}
+
+// Hyphenation is the registry used for rendering; fill it with ScanDirs
+var Hyphenation = &HyphenRegistry{
+	paths:  make(map[string]string),
+	loaded: make(map[string]*Hyphenator),
+}
+
+// ScanDirs registers the hyphenation dictionaries in dirs (hyph_de_DE.dic
+// or hyph-de-1996.pat.txt) and returns how many languages were added
+// A language found in an earlier directory is not replaced
+func (r *HyphenRegistry) ScanDirs(dirs ...string) int {
+	found := 0
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			lang, ok := hyphenLanguage(d.Name())
+			if !ok {
+				return nil
+			}
+			r.mu.Lock()
+			if _, dup := r.paths[lang]; !dup {
+				r.paths[lang] = path
+				found++
+			}
+			r.mu.Unlock()
+			return nil
+		})
+	}
+	return found
+}
+
+// hyphenLanguage returns the language of a hyphenation dictionary file name
+func hyphenLanguage(name string) (string, bool) {
+	for _, ext := range []string{".dic", ".pat.txt"} {
+		if strings.HasSuffix(name, ext) && len(name) > len("hyph_")+len(ext) &&
+			(strings.HasPrefix(name, "hyph_") || strings.HasPrefix(name, "hyph-")) {
+			return normalizeLanguage(name[len("hyph_") : len(name)-len(ext)]), true
+		}
+	}
+	return "", false
+}
+
+// normalizeLanguage writes language tags one way: "de-at" becomes "de_AT"
+func normalizeLanguage(lang string) string {
+	parts := strings.Split(strings.ReplaceAll(lang, "-", "_"), "_")
+	for i, p := range parts {
+		if i > 0 && len(p) == 2 {
+			parts[i] = strings.ToUpper(p)
+		} else {
+			parts[i] = strings.ToLower(p)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// Languages returns the languages with a dictionary, sorted
+func (r *HyphenRegistry) Languages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	langs := make([]string, 0, len(r.paths))
+	for lang := range r.paths {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Hyphenator returns the hyphenator for a language such as "de", "de-AT" or
+// "en_US"; a language without a region matches any of its regions, and one
+// with a region falls back to the language alone
+func (r *HyphenRegistry) Hyphenator(lang string) (*Hyphenator, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.match(normalizeLanguage(lang))
+	if !ok {
+		return nil, fmt.Errorf("no hyphenation dictionary for %q", lang)
+	}
+	if h, ok := r.loaded[key]; ok {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(r.paths[key])
+	if err != nil {
+		return nil, err
+	}
+	h, err := ParseHyphenPatterns(data, strings.HasSuffix(r.paths[key], ".dic"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.paths[key], err)
+	}
+	r.loaded[key] = h
+	return h, nil
+}
+
+// match finds the registered language for lang
+func (r *HyphenRegistry) match(lang string) (string, bool) {
+	if _, ok := r.paths[lang]; ok {
+		return lang, true
+	}
+	base, _, _ := strings.Cut(lang, "_")
+	if _, ok := r.paths[base]; ok {
+		return base, true
+	}
+
+	var candidates []string
+	for l := range r.paths {
+		if strings.HasPrefix(l, base+"_") {
+			candidates = append(candidates, l)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	sort.Strings(candidates)
+	return candidates[0], true
+}
+
+// UserHyphenDir is where users can drop extra hyphenation dictionaries
+// (e.g. ~/.config/nelko-print/hyphen), or "" if there is no config directory
+func UserHyphenDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "nelko-print", "hyphen")
+}
+
+// HyphenDirs lists the directories searched for hyphenation dictionaries,
+// the user directory first
+func HyphenDirs() []string {
+	var dirs []string
+	if dir := UserHyphenDir(); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return append(dirs, systemHyphenDirs()...)
+}
@@ -0,0 +1,67 @@
+package imaging
+
+import (
+	"reflect"
+	"testing"
+)
+
+// liangPatterns are the patterns Liang's thesis uses to hyphenate
+// "hyphenation"
+const liangPatterns = "hy3ph he2n hena4 hen5at 1na n2at 1tio 2io o2n"
+
+func TestHyphenate(t *testing.T) {
+	h, err := ParseHyphenPatterns([]byte(liangPatterns), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		word string
+		want []int
+	}{
+		{"hyphenation", []int{2, 6}},
+		{"Hyphenation", []int{2, 6}},
+		{"HYPHENATION", []int{2, 6}},
+		{"nation", []int{2}},
+		{"ination", []int{3}},
+		// İ lower-cases to two runes with strings.ToLower
+		{"İnation", []int{3}},
+		{"hy", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := h.Hyphenate(tt.word); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Hyphenate(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestHyphenMinimums(t *testing.T) {
+	h, err := ParseHyphenPatterns([]byte("LEFTHYPHENMIN 3\nRIGHTHYPHENMIN 4\n"+liangPatterns), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hy-phen-ation loses the break two letters in
+	if got := h.Hyphenate("hyphenation"); !reflect.DeepEqual(got, []int{6}) {
+		t.Errorf("Hyphenate(hyphenation) = %v, want [6]", got)
+	}
+}
+
+func TestParseHyphenDic(t *testing.T) {
+	// A LibreOffice dictionary in Latin-1: "ä" is the byte 0xE4
+	dic := []byte("ISO8859-1\nLEFTHYPHENMIN 2\nRIGHTHYPHENMIN 2\n\xe41b\n")
+	h, err := ParseHyphenPatterns(dic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Hyphenate("Käbe"); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("Hyphenate(Käbe) = %v, want [2]", got)
+	}
+
+	if _, err := ParseHyphenPatterns([]byte("EBCDIC-XYZ\nab1c\n"), true); err == nil {
+		t.Error("unknown encoding was accepted")
+	}
+	if _, err := ParseHyphenPatterns([]byte("% only a comment\n"), false); err == nil {
+		t.Error("file without patterns was accepted")
+	}
+}
@@ -0,0 +1,199 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/vector"
+)
+
+// iconPath is a closed polygon in a unit square, y pointing down
+// Holes wind the opposite way to the outline around them
+type iconPath [][2]float32
+
+// icon is a built-in vector icon or a user's image
+type icon struct {
+	paths []iconPath
+	img   image.Image
+}
+
+// IconRegistry holds the icons that can be placed inline in text
+// It is safe for concurrent use
+type IconRegistry struct {
+	mu    sync.RWMutex
+	icons map[string]icon
+}
+
+// Icons is the registry used for rendering, with the built-in icons
+// (warning, bolt, check, cross, arrow, star and bullet)
+var Icons = newIconRegistry()
+
+func newIconRegistry() *IconRegistry {
+	r := &IconRegistry{icons: make(map[string]icon)}
+	for name, paths := range builtinIcons() {
+		r.icons[name] = icon{paths: paths}
+	}
+	return r
+}
+
+// Register adds an image as an icon, replacing any icon of the same name
+// Dark, opaque pixels are drawn; the image is scaled to the text's cap height
+func (r *IconRegistry) Register(name string, img image.Image) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.icons[strings.ToLower(name)] = icon{img: img}
+}
+
+// Has reports whether an icon is registered under name
+func (r *IconRegistry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.icons[strings.ToLower(name)]
+	return ok
+}
+
+// Names returns the registered icon names, sorted
+func (r *IconRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.icons))
+	for name := range r.icons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadDir registers every image in dir as an icon named after its file
+// (warning.png becomes "warning") and returns how many were loaded
+func (r *IconRegistry) LoadDir(dir string) int {
+	found := 0
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		img, err := LoadImage(path)
+		if err != nil {
+			return nil
+		}
+		r.Register(strings.TrimSuffix(d.Name(), filepath.Ext(d.Name())), img)
+		found++
+		return nil
+	})
+	return found
+}
+
+// mask draws an icon into a size x size alpha mask, or returns nil if there
+// is no such icon
+func (r *IconRegistry) mask(name string, size int) *image.Alpha {
+	r.mu.RLock()
+	ic, ok := r.icons[strings.ToLower(name)]
+	r.mu.RUnlock()
+	if !ok || size <= 0 {
+		return nil
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, size, size))
+	if ic.img != nil {
+		drawIconImage(mask, ic.img)
+		return mask
+	}
+
+	var rast vector.Rasterizer
+	rast.Reset(size, size)
+	s := float32(size)
+	for _, p := range ic.paths {
+		rast.MoveTo(p[0][0]*s, p[0][1]*s)
+		for _, pt := range p[1:] {
+			rast.LineTo(pt[0]*s, pt[1]*s)
+		}
+		rast.ClosePath()
+	}
+	rast.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	return mask
+}
+
+// drawIconImage scales img into mask, keeping its aspect ratio, and marks its
+// dark, opaque pixels
+func drawIconImage(mask *image.Alpha, img image.Image) {
+	size := mask.Rect.Dx()
+	_, w, h := fitScale(img.Bounds(), size, size)
+	scaled := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	ox, oy := (size-w)/2, (size-h)/2
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Premultiplied, so a dark pixel's gray is below half its alpha
+			c := scaled.RGBAAt(x, y)
+			if c.A >= 0x80 && rgbToGray(c) < c.A/2 {
+				mask.SetAlpha(ox+x, oy+y, color.Alpha{A: 0xFF})
+			}
+		}
+	}
+}
+
+// UserIconDir is where users can drop their own icons for text labels
+// (e.g. ~/.config/nelko-print/icons), or "" if there is no config directory
+func UserIconDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "nelko-print", "icons")
+}
+
+// builtinIcons returns the outlines of the built-in icons
+func builtinIcons() map[string][]iconPath {
+	rect := func(x0, y0, x1, y1 float32) iconPath {
+		// Counter-clockwise on screen, so it cuts a hole in a clockwise outline
+		return iconPath{{x0, y0}, {x0, y1}, {x1, y1}, {x1, y0}}
+	}
+
+	star := make(iconPath, 10)
+	for i := range star {
+		r := 0.5
+		if i%2 == 1 {
+			r = 0.2
+		}
+		a := math.Pi * (float64(i)/5 - 0.5)
+		star[i] = [2]float32{float32(0.5 + r*math.Cos(a)), float32(0.55 + r*math.Sin(a))}
+	}
+
+	bullet := make(iconPath, 24)
+	for i := range bullet {
+		a := 2 * math.Pi * float64(i) / float64(len(bullet))
+		bullet[i] = [2]float32{float32(0.5 + 0.3*math.Cos(a)), float32(0.5 + 0.3*math.Sin(a))}
+	}
+
+	return map[string][]iconPath{
+		"warning": {
+			{{0.5, 0.02}, {0.98, 0.95}, {0.02, 0.95}},
+			rect(0.44, 0.35, 0.56, 0.70),
+			rect(0.44, 0.76, 0.56, 0.87),
+		},
+		"bolt": {
+			{{0.62, 0}, {0.18, 0.56}, {0.46, 0.56}, {0.36, 1}, {0.84, 0.40}, {0.55, 0.40}, {0.72, 0}},
+		},
+		"check": {
+			{{0.08, 0.52}, {0.22, 0.38}, {0.40, 0.56}, {0.78, 0.16}, {0.92, 0.30}, {0.40, 0.84}},
+		},
+		"cross": {
+			{{0.15, 0.05}, {0.5, 0.4}, {0.85, 0.05}, {0.95, 0.15}, {0.6, 0.5}, {0.95, 0.85},
+				{0.85, 0.95}, {0.5, 0.6}, {0.15, 0.95}, {0.05, 0.85}, {0.4, 0.5}, {0.05, 0.15}},
+		},
+		"arrow": {
+			{{0.05, 0.4}, {0.55, 0.4}, {0.55, 0.15}, {0.95, 0.5}, {0.55, 0.85}, {0.55, 0.6}, {0.05, 0.6}},
+		},
+		"star":   {star},
+		"bullet": {bullet},
+	}
+}
@@ -3,6 +3,7 @@ package imaging
 import (
 	"image"
 	"image/color"
+	"image/draw"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
@@ -24,59 +25,62 @@ func LoadImage(path string) (image.Image, error) {
 	return img, err
 }
 
-// ToMonochrome converts an image to 1-bit monochrome bitmap
-// Returns raw bytes suitable for TSPL BITMAP command
-// Width must be divisible by 8
-func ToMonochrome(img image.Image, width, height int, threshold uint8, invert bool) []byte {
-	// Resize/fit image to target dimensions
-	resized := resizeToFit(img, width, height)
-
-	// Width in bytes (8 pixels per byte)
-	widthBytes := width / 8
-	data := make([]byte, widthBytes*height)
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			// Get pixel, convert to grayscale
-			var gray uint8
-			if x < resized.Bounds().Dx() && y < resized.Bounds().Dy() {
-				c := resized.At(resized.Bounds().Min.X+x, resized.Bounds().Min.Y+y)
-				gray = rgbToGray(c)
-			} else {
-				gray = 255 // white for out of bounds
-			}
-
-			// Apply threshold
-			var bit uint8
-			if gray < threshold {
-				bit = 1 // dark pixel
-			}
+// ToMonochrome converts an image to a width x height label bitmap, swapping
+// black and white if invert is set
+func ToMonochrome(img image.Image, width, height int, threshold uint8, invert bool) *Bitmap {
+	bm := ToBitmap(img, width, height, threshold)
+	if invert {
+		bm.Invert()
+	}
+	return bm
+}
 
-			if invert {
-				bit = 1 - bit
+// ToBitmap fits an image into width x height dots and thresholds it
+// Pixels darker than threshold become black dots; the area the image does not
+// cover stays white
+func ToBitmap(img image.Image, width, height int, threshold uint8) *Bitmap {
+	bm := NewBitmap(image.Rect(0, 0, width, height))
+
+	// Sample the source directly at the resized positions
+	src := img.Bounds()
+	scale, newW, newH := fitScale(src, width, height)
+	reader := newRGBAReader(img)
+	row := make([]color.RGBA, src.Dx())
+	rowY := -1
+
+	for y := 0; y < height && y < newH; y++ {
+		srcY := min(int(float64(y)/scale), src.Dy()-1)
+		// Neighbouring label rows often sample the same source row
+		if srcY != rowY {
+			reader.row(src.Min.Y+srcY, row)
+			rowY = srcY
+		}
+		for x := 0; x < width && x < newW; x++ {
+			srcX := min(int(float64(x)/scale), src.Dx()-1)
+			// Convert to grayscale and apply threshold
+			if rgbToGray(row[srcX]) < threshold {
+				i, mask := bm.offset(x, y)
+				bm.Pix[i] |= mask
 			}
-
-			// Pack into byte (MSB first)
-			byteIdx := y*widthBytes + x/8
-			bitIdx := 7 - (x % 8)
-			data[byteIdx] |= bit << bitIdx
 		}
 	}
 
-	return data
+	return bm
 }
 
 // rgbToGray converts a color to grayscale value
-func rgbToGray(c color.Color) uint8 {
-	r, g, b, _ := c.RGBA()
-	// Standard luminance formula, values are 16-bit so divide by 256
+func rgbToGray(c color.RGBA) uint8 {
+	// Standard luminance formula on 16-bit channel values, divided by 256
+	r := uint32(c.R) * 0x101
+	g := uint32(c.G) * 0x101
+	b := uint32(c.B) * 0x101
 	gray := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 256
 	return uint8(gray)
 }
 
-// resizeToFit scales image to fit within bounds while maintaining aspect ratio
-func resizeToFit(img image.Image, maxW, maxH int) image.Image {
-	bounds := img.Bounds()
+// fitScale returns the scale factor and size that fit bounds within maxW x maxH
+// while maintaining aspect ratio
+func fitScale(bounds image.Rectangle, maxW, maxH int) (float64, int, int) {
 	srcW := bounds.Dx()
 	srcH := bounds.Dy()
 
@@ -88,47 +92,57 @@ func resizeToFit(img image.Image, maxW, maxH int) image.Image {
 		scale = scaleH
 	}
 
-	newW := int(float64(srcW) * scale)
-	newH := int(float64(srcH) * scale)
+	return scale, int(float64(srcW) * scale), int(float64(srcH) * scale)
+}
 
-	// Simple nearest-neighbor resize (good enough for thermal printing)
-	dst := image.NewRGBA(image.Rect(0, 0, newW, newH))
+// PreviewMonochrome creates a viewable image from a bitmap
+func PreviewMonochrome(bm *Bitmap) image.Image {
+	r := bm.Bounds()
+	img := image.NewGray(image.Rect(0, 0, r.Dx(), r.Dy()))
 
-	for y := 0; y < newH; y++ {
-		for x := 0; x < newW; x++ {
-			srcX := int(float64(x) / scale)
-			srcY := int(float64(y) / scale)
-			if srcX >= srcW {
-				srcX = srcW - 1
-			}
-			if srcY >= srcH {
-				srcY = srcH - 1
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			if bm.Black(r.Min.X+x, r.Min.Y+y) {
+				img.SetGray(x, y, color.Gray{0}) // black
+			} else {
+				img.SetGray(x, y, color.Gray{255}) // white
 			}
-			dst.Set(x, y, img.At(bounds.Min.X+srcX, bounds.Min.Y+srcY))
 		}
 	}
 
-	return dst
+	return img
 }
 
-// PreviewMonochrome creates a viewable image from monochrome bitmap data
-func PreviewMonochrome(data []byte, width, height int) image.Image {
-	widthBytes := width / 8
-	img := image.NewGray(image.Rect(0, 0, width, height))
+// PreviewCorners greys out the area outside a label's rounded corners so the
+// preview shows where nothing will be printed
+func PreviewCorners(img image.Image, radius int) image.Image {
+	if radius <= 0 {
+		return img
+	}
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			byteIdx := y*widthBytes + x/8
-			bitIdx := 7 - (x % 8)
-			bit := (data[byteIdx] >> bitIdx) & 1
+	bounds := img.Bounds()
+	dst := image.NewGray(bounds)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
 
-			if bit == 1 {
-				img.SetGray(x, y, color.Gray{0}) // black
-			} else {
-				img.SetGray(x, y, color.Gray{255}) // white
+	w, h := bounds.Dx(), bounds.Dy()
+	if limit := min(w, h) / 2; radius > limit {
+		radius = limit
+	}
+
+	outside := color.Gray{200}
+	r2 := radius * radius
+	for y := 0; y < radius; y++ {
+		for x := 0; x < radius; x++ {
+			dx, dy := radius-x, radius-y
+			if dx*dx+dy*dy <= r2 {
+				continue
 			}
+			dst.SetGray(bounds.Min.X+x, bounds.Min.Y+y, outside)
+			dst.SetGray(bounds.Max.X-1-x, bounds.Min.Y+y, outside)
+			dst.SetGray(bounds.Min.X+x, bounds.Max.Y-1-y, outside)
+			dst.SetGray(bounds.Max.X-1-x, bounds.Max.Y-1-y, outside)
 		}
 	}
 
-	return img
+	return dst
 }
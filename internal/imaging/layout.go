@@ -0,0 +1,358 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// objectReplacement stands in for an inline icon in styled text
+const objectReplacement = '\uFFFC'
+
+// styledText is text with a style for each rune
+type styledText struct {
+	text   []rune
+	styles []SpanStyle
+}
+
+// newStyledText flattens spans; each icon becomes one objectReplacement
+func newStyledText(spans []Span) styledText {
+	var t styledText
+	for _, sp := range spans {
+		if sp.Style.Icon != "" {
+			t.text = append(t.text, objectReplacement)
+			t.styles = append(t.styles, sp.Style)
+			continue
+		}
+		for _, r := range sp.Text {
+			t.text = append(t.text, r)
+			t.styles = append(t.styles, sp.Style)
+		}
+	}
+	return t
+}
+
+func (t styledText) String() string {
+	return string(t.text)
+}
+
+// slice returns runes i to j; appending to the result copies it
+func (t styledText) slice(i, j int) styledText {
+	return styledText{t.text[i:j:j], t.styles[i:j:j]}
+}
+
+// split returns the parts of t between each sep
+func (t styledText) split(sep rune) []styledText {
+	var parts []styledText
+	start := 0
+	for i, r := range t.text {
+		if r == sep {
+			parts = append(parts, t.slice(start, i))
+			start = i + 1
+		}
+	}
+	return append(parts, t.slice(start, len(t.text)))
+}
+
+// fields splits t around runs of white space, returning the words and the
+// style of the white space before each word (for rejoining them)
+func (t styledText) fields() ([]styledText, []SpanStyle) {
+	var words []styledText
+	var seps []SpanStyle
+	var sep SpanStyle
+	start := -1
+	for i, r := range t.text {
+		switch {
+		case IsWhitespace(r):
+			if start >= 0 {
+				words = append(words, t.slice(start, i))
+				start = -1
+			}
+			sep = t.styles[i]
+		case start < 0:
+			start = i
+			seps = append(seps, sep)
+		}
+	}
+	if start >= 0 {
+		words = append(words, t.slice(start, len(t.text)))
+	}
+	return words, seps
+}
+
+// spaces counts the spaces in t, which justified text widens
+func (t styledText) spaces() int {
+	n := 0
+	for _, r := range t.text {
+		if r == ' ' {
+			n++
+		}
+	}
+	return n
+}
+
+// shaperKey identifies the font and size a span style is shaped with
+type shaperKey struct {
+	bold, italic bool
+	size         float64
+}
+
+// textLayout measures and draws lines of styled text, shaping each run of
+// one style with that style's fonts
+// It is not safe for concurrent use
+type textLayout struct {
+	opts    TextOptions
+	base    *textShaper
+	shapers map[shaperKey]*textShaper
+}
+
+// newTextLayout prepares the options' fonts; styles' fonts load when needed
+func newTextLayout(opts TextOptions) (*textLayout, error) {
+	base, err := newTextShaper(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &textLayout{
+		opts:    opts,
+		base:    base,
+		shapers: map[shaperKey]*textShaper{{size: 1}: base},
+	}, nil
+}
+
+// shaper returns the shaper for a style, or the label font's if the style's
+// font does not load
+func (l *textLayout) shaper(st SpanStyle) *textShaper {
+	key := shaperKey{bold: st.Bold, italic: st.Italic, size: st.scale()}
+	if s, ok := l.shapers[key]; ok {
+		return s
+	}
+
+	opts := l.opts
+	opts.Bold = opts.Bold || st.Bold
+	opts.Italic = opts.Italic || st.Italic
+	opts.FontSize *= key.size
+	s, err := newTextShaper(opts)
+	if err != nil {
+		s = l.base
+	}
+	l.shapers[key] = s
+	return s
+}
+
+// setSize changes the label font size, resizing the shapers of every style
+// rather than loading them again
+func (l *textLayout) setSize(fontSize float64) error {
+	l.opts.FontSize = fontSize
+	for key, s := range l.shapers {
+		// A style whose fonts did not load shares the label font's shaper
+		if s == l.base && key != (shaperKey{size: 1}) {
+			continue
+		}
+		if err := s.setSize(fontSize * key.size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metrics returns the largest ascent, descent and height of the styles in a
+// line and of the fallback fonts its characters are drawn in, or the label
+// font's for an empty line
+// Fallback fonts count because CJK and Arabic fonts often reach well above
+// and below the chosen font
+func (l *textLayout) metrics(line styledText) font.Metrics {
+	if len(line.styles) == 0 {
+		return l.base.metrics()
+	}
+
+	type usedFont struct {
+		shaper *textShaper
+		index  int // in the shaper's chain
+	}
+	var m font.Metrics
+	seen := make(map[usedFont]bool)
+	add := func(s *textShaper, i int) {
+		if seen[usedFont{s, i}] {
+			return
+		}
+		seen[usedFont{s, i}] = true
+		fm := s.fonts[i].cover.Metrics()
+		m.Ascent = max(m.Ascent, fm.Ascent)
+		m.Descent = max(m.Descent, fm.Descent)
+		m.Height = max(m.Height, fm.Height)
+	}
+
+	for i, st := range line.styles {
+		s := l.shaper(st)
+		add(s, 0)
+		if r := line.text[i]; st.Icon == "" && !IsWhitespace(r) {
+			add(s, s.fontFor(r))
+		}
+	}
+	return m
+}
+
+// blockHeight is the height of lines; extra line spacing goes between lines,
+// not after the last one
+func (l *textLayout) blockHeight(lines []textLine) int {
+	h := 0
+	for i, line := range lines {
+		m := l.metrics(line.text)
+		if i == len(lines)-1 {
+			h += m.Height.Ceil()
+		} else {
+			h += l.opts.lineHeight(m)
+		}
+	}
+	return h
+}
+
+// shape shapes a line and returns its runs in visual (left to right) order
+func (l *textLayout) shape(line styledText) []shapedRun {
+	if len(line.text) == 0 {
+		return nil
+	}
+
+	base := paragraphDirection(line.text)
+	dirs := bidiDirections(line.text, base)
+	var runs []shapedRun
+	for i := 0; i < len(line.text); {
+		st, dir := line.styles[i], dirs[i]
+		j := i + 1
+		for j < len(line.text) && line.styles[j] == st && dirs[j] == dir {
+			j++
+		}
+
+		if st.Icon != "" {
+			for k := i; k < j; k++ {
+				runs = append(runs, l.iconRun(st, bidiLevel(dir, base), k))
+			}
+		} else {
+			shaped := l.shaper(st).shapeRuns(line.text, i, j, dir, base)
+			for k := range shaped {
+				shaped[k].style = st
+			}
+			runs = append(runs, shaped...)
+		}
+		i = j
+	}
+
+	reorderRuns(runs)
+	return runs
+}
+
+// iconRun is a run holding one inline icon, as wide as the icon plus a
+// little space either side, for the icon at rune index i of a line
+func (l *textLayout) iconRun(st SpanStyle, level, i int) shapedRun {
+	s := l.shaper(st)
+	size := iconSize(s.metrics())
+	run := shapedRun{shaper: s, style: st, level: level, icon: i}
+	run.out.Advance = fixed.I(size+2*iconPad(size)) + s.track
+	return run
+}
+
+// iconSize is the height of inline icons: the font's cap height
+func iconSize(m font.Metrics) int {
+	if m.CapHeight > 0 {
+		return m.CapHeight.Ceil()
+	}
+	return (m.Ascent * 7 / 10).Ceil()
+}
+
+// iconPad is the space either side of an inline icon
+func iconPad(size int) int {
+	return max(1, size/8)
+}
+
+// measure returns the width of a line in pixels
+func (l *textLayout) measure(line styledText) int {
+	var width fixed.Int26_6
+	for _, r := range l.shape(line) {
+		width += r.out.Advance
+	}
+	return width.Ceil()
+}
+
+// advances returns how far each character of a line moves the pen, from one
+// shaping of the whole line; a cluster's advance counts for its first
+// character, so the width of part of the line is a sum rather than a new
+// shaping
+func (l *textLayout) advances(line styledText) []fixed.Int26_6 {
+	adv := make([]fixed.Int26_6, len(line.text))
+	for _, run := range l.shape(line) {
+		if run.style.Icon != "" {
+			adv[run.icon] += run.out.Advance
+			continue
+		}
+		for _, g := range run.out.Glyphs {
+			adv[g.ClusterIndex] += g.XAdvance
+		}
+	}
+	return adv
+}
+
+// draw draws a line with its left end of the baseline at (x, y), widening
+// each space by wordSpace (for justified text)
+func (l *textLayout) draw(dst draw.Image, src image.Image, x, y int, line styledText, wordSpace fixed.Int26_6) {
+	dot := fixed.P(x, y)
+	for _, run := range l.shape(line) {
+		start := dot.X
+		if run.style.Icon != "" {
+			drawIcon(dst, src, run, dot)
+			dot.X += run.out.Advance
+		}
+		for _, g := range run.out.Glyphs {
+			if wordSpace != 0 && g.ClusterIndex < len(run.text) && run.text[g.ClusterIndex] == ' ' {
+				dot.X += wordSpace
+			}
+			// Glyph 0 is .notdef; missing characters are reported, not drawn
+			if g.GlyphID != 0 {
+				pos := fixed.Point26_6{X: dot.X + g.XOffset, Y: dot.Y - g.YOffset}
+				run.shaper.drawGlyph(dst, src, run.font, sfnt.GlyphIndex(g.GlyphID), pos)
+			}
+			dot.X += g.XAdvance
+		}
+		decorate(dst, src, run, start, dot.X, y)
+	}
+}
+
+// drawIcon draws an icon run's icon standing on the baseline at dot
+func drawIcon(dst draw.Image, src image.Image, run shapedRun, dot fixed.Point26_6) {
+	size := iconSize(run.shaper.metrics())
+	mask := Icons.mask(run.style.Icon, size)
+	if mask == nil {
+		return
+	}
+	x := dot.X.Round() + iconPad(size)
+	r := image.Rect(x, dot.Y.Round()-size, x+size, dot.Y.Round())
+	draw.DrawMask(dst, r, src, image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// decorate underlines or strikes through a run from x0 to x1, with the
+// baseline at y
+func decorate(dst draw.Image, src image.Image, run shapedRun, x0, x1 fixed.Int26_6, y int) {
+	if !run.style.Underline && !run.style.Strike {
+		return
+	}
+
+	em := run.shaper.size.Round()
+	thick := max(1, int(math.Round(float64(em)/18)))
+	rule := func(top int) {
+		draw.Draw(dst, image.Rect(x0.Floor(), top, x1.Ceil(), top+thick), src, image.Point{}, draw.Over)
+	}
+
+	if run.style.Underline {
+		rule(y + max(1, em/10))
+	}
+	if run.style.Strike {
+		mid := em / 4
+		if xh := run.shaper.metrics().XHeight; xh > 0 {
+			mid = xh.Round() / 2
+		}
+		rule(y - mid - thick/2)
+	}
+}
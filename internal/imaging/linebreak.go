@@ -0,0 +1,315 @@
+package imaging
+
+import (
+	"sort"
+	"unicode"
+
+	"github.com/go-text/typesetting/segmenter"
+	"golang.org/x/image/math/fixed"
+)
+
+// softHyphen marks where a word may be hyphenated; it is only shown, as a
+// hyphen, when a line breaks there
+const softHyphen = '\u00AD'
+
+// breakPoint is a place a line may end
+type breakPoint struct {
+	pos    int  // rune offset where the next line starts
+	hyphen bool // the line ends inside a word, so a hyphen is added
+}
+
+// lineMeasurer measures lines of styled text, and the advance of each
+// character of a line shaped once, so the widths of its parts can be summed
+type lineMeasurer interface {
+	measure(line styledText) int
+	advances(line styledText) []fixed.Int26_6
+}
+
+// lineBreaker wraps paragraphs between words following the Unicode line
+// breaking rules (UAX #14), hyphenating words if it has a dictionary
+// It is not safe for concurrent use
+type lineBreaker struct {
+	lineMeasurer
+	maxWidth int
+	hyph     *Hyphenator // nil to only break at soft hyphens
+	balanced bool        // minimum raggedness instead of filling each line
+	seg      segmenter.Segmenter
+}
+
+// newLineBreaker prepares a breaker for the options' hyphenation language
+// and breaking mode; a language without a dictionary is not hyphenated
+func (opts TextOptions) newLineBreaker(m lineMeasurer, maxWidth int) *lineBreaker {
+	b := &lineBreaker{lineMeasurer: m, maxWidth: maxWidth, balanced: opts.Balanced}
+	if opts.Hyphenate != "" {
+		b.hyph, _ = Hyphenation.Hyphenator(opts.Hyphenate)
+	}
+	return b
+}
+
+// wrap splits a paragraph into lines that fit within maxWidth, breaking
+// inside a word only if it is wider than a line on its own
+func (b *lineBreaker) wrap(para styledText) []styledText {
+	if len(para.text) == 0 {
+		return nil
+	}
+
+	points := b.breakPoints(para)
+	widths := b.newLineWidths(para, points)
+	if widths.line(0, len(points)-1) <= b.maxWidth {
+		return []styledText{lineText(para, 0, points[len(points)-1])}
+	}
+	if b.balanced {
+		return b.balance(para, points, widths)
+	}
+	return b.fill(para, points, widths)
+}
+
+// lineWidths measures each piece of a paragraph between break points once,
+// so the width of a candidate line is a sum rather than a new shaping of
+// the whole line
+// Kerning across a break point is lost, which only matters inside words
+// broken by hyphenation
+type lineWidths struct {
+	b      *lineBreaker
+	para   styledText
+	points []breakPoint
+	sum    []int           // sum[k] is the width of the pieces before points[k] in mid-line form
+	end    []int           // end[k] is the width of piece k ending a line
+	pen    []fixed.Int26_6 // pen[i] is the advance of the runes before i, once a word is broken
+}
+
+// newLineWidths measures the pieces of para ending at each break point:
+// piece k runs from the previous break point to points[k]
+func (b *lineBreaker) newLineWidths(para styledText, points []breakPoint) *lineWidths {
+	w := &lineWidths{
+		b:      b,
+		para:   para,
+		points: points,
+		sum:    make([]int, len(points)+1),
+		end:    make([]int, len(points)),
+	}
+	start := 0
+	for k, p := range points {
+		w.sum[k+1] = w.sum[k] + b.measure(pieceText(para, start, p.pos))
+		w.end[k] = b.measure(lineText(para, start, p))
+		start = p.pos
+	}
+	return w
+}
+
+// line returns the width of the line from rune start to points[j]
+func (w *lineWidths) line(start, j int) int {
+	// The piece start is in, which it begins unless a long word was broken
+	i := sort.Search(j, func(k int) bool { return w.points[k].pos > start })
+	if i == 0 && start == 0 || i > 0 && start == w.points[i-1].pos {
+		return w.sum[j] - w.sum[i] + w.end[j]
+	}
+	if i == j {
+		return w.b.measure(lineText(w.para, start, w.points[j]))
+	}
+	return w.b.measure(pieceText(w.para, start, w.points[i].pos)) + w.sum[j] - w.sum[i+1] + w.end[j]
+}
+
+// span returns the width of runes start to end from one shaping of the
+// whole paragraph
+func (w *lineWidths) span(start, end int) int {
+	if w.pen == nil {
+		w.pen = prefixSums(w.b.advances(w.para))
+	}
+	return (w.pen[end] - w.pen[start]).Ceil()
+}
+
+// prefixSums returns the sums of the advances before each rune and the end
+func prefixSums(adv []fixed.Int26_6) []fixed.Int26_6 {
+	pen := make([]fixed.Int26_6, len(adv)+1)
+	for i, a := range adv {
+		pen[i+1] = pen[i] + a
+	}
+	return pen
+}
+
+// pieceText returns the text from start to end as it appears inside a
+// line: soft hyphens are dropped and white space kept
+func pieceText(para styledText, start, end int) styledText {
+	var piece styledText
+	for i := start; i < end; i++ {
+		if para.text[i] != softHyphen {
+			piece.text = append(piece.text, para.text[i])
+			piece.styles = append(piece.styles, para.styles[i])
+		}
+	}
+	return piece
+}
+
+// breakPoints returns where lines of para may end, in order, finishing at
+// the end of the paragraph
+func (b *lineBreaker) breakPoints(para styledText) []breakPoint {
+	var points []breakPoint
+	b.seg.Init(para.text)
+	iter := b.seg.LineIterator()
+	for iter.Next() {
+		line := iter.Line()
+		end := line.Offset + len(line.Text)
+		if b.hyph != nil {
+			points = append(points, b.hyphenPoints(para, line.Offset, end)...)
+		}
+		soft := para.text[end-1] == softHyphen && end < len(para.text)
+		points = append(points, breakPoint{pos: end, hyphen: soft})
+	}
+	if n := len(points); n == 0 || points[n-1].pos != len(para.text) {
+		points = append(points, breakPoint{pos: len(para.text)})
+	}
+	return points
+}
+
+// hyphenPoints returns the dictionary hyphenation points of the words
+// between start and end
+func (b *lineBreaker) hyphenPoints(para styledText, start, end int) []breakPoint {
+	var points []breakPoint
+	for i := start; i < end; {
+		if !unicode.IsLetter(para.text[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < end && unicode.IsLetter(para.text[j]) {
+			j++
+		}
+		for _, k := range b.hyph.Hyphenate(string(para.text[i:j])) {
+			points = append(points, breakPoint{pos: i + k, hyphen: true})
+		}
+		i = j
+	}
+	return points
+}
+
+// lineText returns the line from start to a break point, without trailing
+// white space or soft hyphens, and with a hyphen if it breaks a word
+func lineText(para styledText, start int, p breakPoint) styledText {
+	end := p.pos
+	for end > start && IsWhitespace(para.text[end-1]) {
+		end--
+	}
+
+	var line styledText
+	for i := start; i < end; i++ {
+		if para.text[i] != softHyphen {
+			line.text = append(line.text, para.text[i])
+			line.styles = append(line.styles, para.styles[i])
+		}
+	}
+	if p.hyphen && end > start {
+		line.text = append(line.text, '-')
+		line.styles = append(line.styles, para.styles[end-1])
+	}
+	return line
+}
+
+// fill puts as much on each line as fits
+func (b *lineBreaker) fill(para styledText, points []breakPoint, widths *lineWidths) []styledText {
+	var lines []styledText
+	start := 0
+	for i := 0; i < len(points); {
+		best := -1
+		for j := i; j < len(points); j++ {
+			if widths.line(start, j) > b.maxWidth {
+				break
+			}
+			best = j
+		}
+
+		if best < 0 {
+			// Not even the next word fits, so it is broken where the line is full
+			pos := b.forceBreak(para, widths, start, points[i].pos)
+			lines = append(lines, lineText(para, start, breakPoint{pos: pos}))
+			start = pos
+			if pos == points[i].pos {
+				i++
+			}
+			continue
+		}
+
+		lines = append(lines, lineText(para, start, points[best]))
+		start = points[best].pos
+		i = best + 1
+	}
+	return lines
+}
+
+// forceBreak returns where to break a word running from start to end that
+// is too wide for a line: after the most characters that fit, at least one
+// Widths come from the paragraph's advances, as measuring each candidate
+// would shape the word again for every character
+func (b *lineBreaker) forceBreak(para styledText, widths *lineWidths, start, end int) int {
+	last := end
+	for last > start && IsWhitespace(para.text[last-1]) {
+		last--
+	}
+
+	pos := start + 1
+	for pos < last && widths.span(start, pos+1) <= b.maxWidth {
+		pos++
+	}
+	if pos >= last {
+		return end
+	}
+	return pos
+}
+
+// balance breaks lines to keep their lengths even, minimising the sum of
+// the squared space left at the end of each line but the last (Knuth and
+// Plass's minimum raggedness), with a penalty for hyphenating
+func (b *lineBreaker) balance(para styledText, points []breakPoint, widths *lineWidths) []styledText {
+	n := len(points)
+	pos := func(i int) int {
+		if i == 0 {
+			return 0
+		}
+		return points[i-1].pos
+	}
+	penalty := float64(b.maxWidth) * float64(b.maxWidth) / 16
+
+	// cost[j] is the least cost of the lines up to points[j-1], reached with
+	// a line starting at from[j]; a negative cost is not reachable
+	cost := make([]float64, n+1)
+	from := make([]int, n+1)
+	for j := 1; j <= n; j++ {
+		cost[j] = -1
+		for i := j - 1; i >= 0; i-- {
+			w := widths.line(pos(i), j-1)
+			if w > b.maxWidth {
+				if i == j-1 {
+					// A word wider than a line has to be broken by filling
+					return b.fill(para, points, widths)
+				}
+				break
+			}
+			if cost[i] < 0 {
+				continue
+			}
+
+			c := cost[i]
+			if j < n {
+				slack := float64(b.maxWidth - w)
+				c += slack * slack
+				if points[j-1].hyphen {
+					c += penalty
+				}
+			}
+			if cost[j] < 0 || c < cost[j] {
+				cost[j], from[j] = c, i
+			}
+		}
+	}
+
+	var ends []int
+	for j := n; j > 0; j = from[j] {
+		ends = append(ends, j)
+	}
+	lines := make([]styledText, 0, len(ends))
+	for k := len(ends) - 1; k >= 0; k-- {
+		j := ends[k]
+		lines = append(lines, lineText(para, pos(from[j]), points[j-1]))
+	}
+	return lines
+}
@@ -0,0 +1,164 @@
+package imaging
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// runeWidths measures text as one pixel per character
+type runeWidths struct{}
+
+func (runeWidths) measure(t styledText) int {
+	return len(t.text)
+}
+
+func (runeWidths) advances(t styledText) []fixed.Int26_6 {
+	adv := make([]fixed.Int26_6, len(t.text))
+	for i := range adv {
+		adv[i] = fixed.I(1)
+	}
+	return adv
+}
+
+// lineStrings returns the text of lines
+func lineStrings(lines []styledText) []string {
+	var s []string
+	for _, l := range lines {
+		s = append(s, l.String())
+	}
+	return s
+}
+
+func TestBreakPoints(t *testing.T) {
+	h, err := ParseHyphenPatterns([]byte(liangPatterns), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		hyph *Hyphenator
+		want []breakPoint
+	}{
+		{"spaces", "one two  three", nil, []breakPoint{{pos: 4}, {pos: 9}, {pos: 14}}},
+		{"after hyphen", "well-known fact", nil, []breakPoint{{pos: 5}, {pos: 11}, {pos: 15}}},
+		{"not before closing punctuation", "end (see notes).", nil, []breakPoint{{pos: 4}, {pos: 9}, {pos: 16}}},
+		{"no break in numbers", "pay 1,000.50 now", nil, []breakPoint{{pos: 4}, {pos: 13}, {pos: 16}}},
+		{"soft hyphen", "Silben­trennung", nil, []breakPoint{{pos: 7, hyphen: true}, {pos: 15}}},
+		{"ideographs", "漢字かな", nil, []breakPoint{{pos: 1}, {pos: 2}, {pos: 3}, {pos: 4}}},
+		{"dictionary", "hyphenation test", h, []breakPoint{{pos: 2, hyphen: true}, {pos: 6, hyphen: true}, {pos: 12}, {pos: 16}}},
+	}
+	for _, tt := range tests {
+		b := &lineBreaker{lineMeasurer: runeWidths{}, maxWidth: 10, hyph: tt.hyph}
+		if got := b.breakPoints(plain(tt.text)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: break points %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	h, err := ParseHyphenPatterns([]byte(liangPatterns), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		width    int
+		balanced bool
+		hyph     *Hyphenator
+		want     []string
+	}{
+		{"fits", "aaa bb", 6, false, nil, []string{"aaa bb"}},
+		{"fill", "aaa bb cc ddddd", 6, false, nil, []string{"aaa bb", "cc", "ddddd"}},
+		// Slack 3² + 1² beats filling's 0² + 4²
+		{"minimum raggedness", "aaa bb cc ddddd", 6, true, nil, []string{"aaa", "bb cc", "ddddd"}},
+		// Counting the last line's slack would give "aaa", "bb c"
+		{"last line is free", "aaa bb c", 6, true, nil, []string{"aaa bb", "c"}},
+		{"long word", "abcdefghij xy", 4, false, nil, []string{"abcd", "efgh", "ij", "xy"}},
+		{"long word balanced", "abcdefghij xy", 4, true, nil, []string{"abcd", "efgh", "ij", "xy"}},
+		{"hyphenated", "the hyphenation", 8, false, h, []string{"the hy-", "phen-", "ation"}},
+		{"soft hyphen", "ab Silben­trennung", 10, false, nil, []string{"ab Silben-", "trennung"}},
+	}
+	for _, tt := range tests {
+		b := &lineBreaker{lineMeasurer: runeWidths{}, maxWidth: tt.width, hyph: tt.hyph, balanced: tt.balanced}
+		if got := lineStrings(b.wrap(plain(tt.text))); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: lines %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLineWidths(t *testing.T) {
+	layout, err := newTextLayout(TextOptions{FontSize: 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	para := newStyledText([]Span{
+		{Text: "Summed widths match "},
+		{Text: "shaping", Style: SpanStyle{Bold: true}},
+		{Text: " whole lines, even Silben­trennung."},
+	})
+	b := &lineBreaker{lineMeasurer: layout, maxWidth: 1000}
+	points := b.breakPoints(para)
+	widths := b.newLineWidths(para, points)
+
+	for j := range points {
+		for start := 0; start < points[j].pos; start++ {
+			want := layout.measure(lineText(para, start, points[j]))
+			if got := widths.line(start, j); got != want {
+				t.Errorf("line %d to %d: summed width %d, want %d", start, points[j].pos, got, want)
+			}
+		}
+	}
+}
+
+func TestAdvances(t *testing.T) {
+	layout, err := newTextLayout(TextOptions{FontSize: 14, LetterSpacing: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []styledText{
+		plain("Summed advances"),
+		newStyledText([]Span{{Text: "bold ", Style: SpanStyle{Bold: true}}, {Style: SpanStyle{Icon: "star"}}, {Text: " مرحبا بالعالم"}}),
+	}
+	for _, line := range tests {
+		var sum fixed.Int26_6
+		for _, a := range layout.advances(line) {
+			sum += a
+		}
+		if got, want := sum.Ceil(), layout.measure(line); got != want {
+			t.Errorf("%q: advances sum to %d, want %d", line, got, want)
+		}
+	}
+}
+
+func TestWrapTextAnywhere(t *testing.T) {
+	layout, err := newTextLayout(TextOptions{FontSize: 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	para := plain("Lines break between any two characters, filled as far as they fit")
+	const maxWidth = 60
+
+	lines := wrapText(para, layout, maxWidth)
+	var joined string
+	for i, line := range lines {
+		joined += line.String()
+		if w := layout.measure(line); w > maxWidth {
+			t.Errorf("line %q is %d wide, over %d", line, w, maxWidth)
+		}
+		if i+1 < len(lines) {
+			next := plain(line.String() + string(lines[i+1].text[0]))
+			if w := layout.measure(next); w <= maxWidth {
+				t.Errorf("line %q ends early: %q is %d wide", line, next, w)
+			}
+		}
+	}
+	if joined != para.String() {
+		t.Errorf("lines join to %q, want %q", joined, para)
+	}
+}
@@ -0,0 +1,239 @@
+package imaging
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Span is a run of text in one style
+type Span struct {
+	Text  string
+	Style SpanStyle
+}
+
+// SpanStyle is the styling of a Span, on top of the label's TextOptions
+type SpanStyle struct {
+	Bold      bool
+	Italic    bool
+	Underline bool
+	Strike    bool
+	Size      float64 // multiple of the label's font size, 0 for 1
+	Icon      string  // name of an inline icon drawn in place of Text
+}
+
+// scale returns the span's size as a multiple of the font size
+func (st SpanStyle) scale() float64 {
+	if st.Size <= 0 {
+		return 1
+	}
+	return st.Size
+}
+
+// markupKind is the kind of a markup token
+type markupKind int
+
+const (
+	markupText      markupKind = iota
+	markupBold                 // **
+	markupItalic               // *
+	markupUnderline            // __
+	markupStrike               // ~~
+	markupSizeOpen             // [size=1.5] or [size=150%]
+	markupSizeClose            // [/size]
+	markupIcon                 // :name:
+)
+
+// markupToken is a piece of markup: literal text or a marker as written
+type markupToken struct {
+	kind markupKind
+	text string
+	size float64 // for markupSizeOpen
+}
+
+// ParseMarkup splits lightweight markup into styled spans:
+//
+//	**bold**  *italic*  __underline__  ~~strike~~
+//	[size=2]twice as big[/size]  [size=50%]half size[/size]
+//	:warning: and other names from Icons for inline icons
+//
+// Markers may nest and cross line breaks; a marker without a partner is
+// kept as text, and a backslash keeps the next character as text
+func ParseMarkup(s string) []Span {
+	tokens := tokenizeMarkup(s)
+	matchMarkup(tokens)
+
+	var spans []Span
+	var style SpanStyle
+	var sizes []float64
+	add := func(text string, st SpanStyle) {
+		if n := len(spans); n > 0 && spans[n-1].Style == st && st.Icon == "" {
+			spans[n-1].Text += text
+			return
+		}
+		spans = append(spans, Span{Text: text, Style: st})
+	}
+
+	for _, t := range tokens {
+		switch t.kind {
+		case markupBold:
+			style.Bold = !style.Bold
+		case markupItalic:
+			style.Italic = !style.Italic
+		case markupUnderline:
+			style.Underline = !style.Underline
+		case markupStrike:
+			style.Strike = !style.Strike
+		case markupSizeOpen:
+			sizes = append(sizes, style.Size)
+			style.Size = style.scale() * t.size
+		case markupSizeClose:
+			style.Size = sizes[len(sizes)-1]
+			sizes = sizes[:len(sizes)-1]
+		case markupIcon:
+			icon := style
+			icon.Icon = strings.Trim(t.text, ":")
+			add(t.text, icon)
+		default:
+			if t.text != "" {
+				add(t.text, style)
+			}
+		}
+	}
+	return spans
+}
+
+// tokenizeMarkup splits markup into text and markers
+func tokenizeMarkup(s string) []markupToken {
+	var tokens []markupToken
+	var text strings.Builder
+	marker := func(kind markupKind, src string, size float64) {
+		if text.Len() > 0 {
+			tokens = append(tokens, markupToken{kind: markupText, text: text.String()})
+			text.Reset()
+		}
+		tokens = append(tokens, markupToken{kind: kind, text: src, size: size})
+	}
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune(`\*_~[]:`, rune(rest[1])):
+			text.WriteByte(rest[1])
+			i += 2
+		case strings.HasPrefix(rest, "**"):
+			marker(markupBold, "**", 0)
+			i += 2
+		case rest[0] == '*':
+			marker(markupItalic, "*", 0)
+			i++
+		case strings.HasPrefix(rest, "__"):
+			marker(markupUnderline, "__", 0)
+			i += 2
+		case strings.HasPrefix(rest, "~~"):
+			marker(markupStrike, "~~", 0)
+			i += 2
+		case strings.HasPrefix(rest, "[/size]"):
+			marker(markupSizeClose, "[/size]", 0)
+			i += len("[/size]")
+		default:
+			if tag, size, ok := parseSizeTag(rest); ok {
+				marker(markupSizeOpen, tag, size)
+				i += len(tag)
+			} else if name, ok := parseIconName(rest); ok {
+				marker(markupIcon, name, 0)
+				i += len(name)
+			} else {
+				text.WriteByte(rest[0])
+				i++
+			}
+		}
+	}
+	if text.Len() > 0 {
+		tokens = append(tokens, markupToken{kind: markupText, text: text.String()})
+	}
+	return tokens
+}
+
+// parseSizeTag reads a [size=N] or [size=N%] tag at the start of s
+func parseSizeTag(s string) (string, float64, bool) {
+	if !strings.HasPrefix(s, "[size=") {
+		return "", 0, false
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return "", 0, false
+	}
+	value := s[len("[size="):end]
+	percent := strings.HasSuffix(value, "%")
+	size, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil || size <= 0 {
+		return "", 0, false
+	}
+	if percent {
+		size /= 100
+	}
+	return s[:end+1], size, true
+}
+
+// parseIconName reads a :name: at the start of s naming a registered icon
+func parseIconName(s string) (string, bool) {
+	if s[0] != ':' {
+		return "", false
+	}
+	end := strings.IndexByte(s[1:], ':')
+	if end <= 0 {
+		return "", false
+	}
+	name := s[1 : end+1]
+	if strings.ContainsAny(name, " \t\n") || !Icons.Has(name) {
+		return "", false
+	}
+	return s[:end+2], true
+}
+
+// matchMarkup turns markers without a partner back into text
+// Toggles pair up in order; size tags nest
+func matchMarkup(tokens []markupToken) {
+	last := make(map[markupKind]int)
+	var open []int
+	for i, t := range tokens {
+		switch t.kind {
+		case markupBold, markupItalic, markupUnderline, markupStrike:
+			if _, ok := last[t.kind]; ok {
+				delete(last, t.kind)
+			} else {
+				last[t.kind] = i
+			}
+		case markupSizeOpen:
+			open = append(open, i)
+		case markupSizeClose:
+			if len(open) == 0 {
+				tokens[i].kind = markupText
+				continue
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	for _, i := range last {
+		tokens[i].kind = markupText
+	}
+	for _, i := range open {
+		tokens[i].kind = markupText
+	}
+}
+
+// spans returns text as styled spans, parsing it as markup if the options
+// ask for it
+func (opts TextOptions) spans(text string) []Span {
+	spans := []Span{{Text: text}}
+	if opts.Markup {
+		spans = ParseMarkup(text)
+	}
+
+	// Underline and strikethrough for the whole text apply to every span
+	for i := range spans {
+		spans[i].Style.Underline = spans[i].Style.Underline || opts.Underline
+		spans[i].Style.Strike = spans[i].Style.Strike || opts.Strike
+	}
+	return spans
+}
@@ -0,0 +1,333 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/go-text/typesetting/di"
+	gotext "github.com/go-text/typesetting/font"
+	tfont "github.com/go-text/typesetting/opentype/api/font"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// chainFont is one font of the fallback chain, ready for shaping and drawing
+type chainFont struct {
+	entry    chainEntry
+	shaping  gotext.Face
+	outlines *opentype.Font
+	cover    font.Face // reports whether the font has a glyph for a rune
+	bold     int       // synthetic bold stroke width in pixels, 0 for none
+}
+
+// shapedRun is a run of glyphs in one font, style and direction
+type shapedRun struct {
+	out    shaping.Output
+	font   *chainFont
+	level  int // bidi embedding level, odd for right-to-left
+	shaper *textShaper
+	text   []rune // the text shaped; glyph clusters index into it
+	style  SpanStyle
+	icon   int // rune index of an icon run's icon in the line
+}
+
+// textShaper lays out lines of text with HarfBuzz-style shaping (kerning,
+// ligatures, contextual forms) and the Unicode bidi algorithm, drawing the
+// shaped glyphs from their outlines
+// It is not safe for concurrent use
+type textShaper struct {
+	fonts []chainFont
+	size  fixed.Int26_6 // pixels per em
+	track fixed.Int26_6 // letter spacing added after each character
+	cache map[rune]int  // chain index used for a rune
+
+	shaper shaping.HarfbuzzShaper
+	seg    shaping.Segmenter
+	buf    sfnt.Buffer
+	rast   vector.Rasterizer
+}
+
+// newTextShaper prepares the options' font and its fallback chain
+func newTextShaper(opts TextOptions) (*textShaper, error) {
+	s := &textShaper{
+		track: fixed.Int26_6(opts.LetterSpacing * 64),
+		cache: make(map[rune]int),
+	}
+
+	for i, entry := range opts.fontChain() {
+		outlines, err := Fonts.Font(entry.name)
+		var shaper *tfont.Font
+		if err == nil {
+			shaper, err = Fonts.ShapingFont(entry.name)
+		}
+		if err == nil {
+			_, err = Fonts.Face(entry.name, opts.FontSize, textDPI, font.HintingNone)
+		}
+		if err != nil {
+			// A broken fallback font is skipped; the chosen font must load
+			if i == 0 {
+				return nil, err
+			}
+			continue
+		}
+		s.fonts = append(s.fonts, chainFont{
+			entry:    entry,
+			shaping:  &tfont.Face{Font: shaper},
+			outlines: outlines,
+		})
+	}
+	return s, s.setSize(opts.FontSize)
+}
+
+// setSize changes the font size in points, keeping the fonts, the choice of
+// font for each rune and the shaper's buffers
+func (s *textShaper) setSize(fontSize float64) error {
+	s.size = fixed.Int26_6(fontSize * textDPI / 72 * 64)
+	for i := range s.fonts {
+		f := &s.fonts[i]
+		cover, err := Fonts.Face(f.entry.name, fontSize, textDPI, font.HintingNone)
+		if err != nil {
+			return err
+		}
+		f.cover = cover
+		f.bold = 0
+		if f.entry.synthBold {
+			f.bold = emboldenStrength(fontSize, textDPI)
+		}
+	}
+	return nil
+}
+
+// ResolveFace picks the first font in the chain with a glyph for r, so the
+// segmenter splits runs where the font changes
+func (s *textShaper) ResolveFace(r rune) gotext.Face {
+	return s.fonts[s.fontFor(r)].shaping
+}
+
+// fontFor returns the chain index of the font used for r
+func (s *textShaper) fontFor(r rune) int {
+	if i, ok := s.cache[r]; ok {
+		return i
+	}
+	i := 0
+	for n, f := range s.fonts {
+		if _, ok := f.cover.GlyphAdvance(r); ok {
+			i = n
+			break
+		}
+	}
+	s.cache[r] = i
+	return i
+}
+
+// metrics returns the line metrics of the chosen font
+func (s *textShaper) metrics() font.Metrics {
+	return s.fonts[0].cover.Metrics()
+}
+
+// chainFont returns the chain entry whose shaping face is face
+func (s *textShaper) chainFont(face gotext.Face) *chainFont {
+	for i := range s.fonts {
+		if s.fonts[i].shaping == face {
+			return &s.fonts[i]
+		}
+	}
+	return &s.fonts[0]
+}
+
+// paragraphDirection returns the direction of the first strong character
+func paragraphDirection(text []rune) di.Direction {
+	for _, r := range text {
+		p, _ := bidi.LookupRune(r)
+		switch p.Class() {
+		case bidi.L:
+			return di.DirectionLTR
+		case bidi.R, bidi.AL:
+			return di.DirectionRTL
+		}
+	}
+	return di.DirectionLTR
+}
+
+// bidiDirections resolves the direction of each character of a line in a
+// paragraph of the base direction, with the Unicode bidi algorithm
+// Resolving the whole line at once, not each style's part of it, lets
+// spaces and punctuation take the direction of the text around them
+func bidiDirections(text []rune, base di.Direction) []di.Direction {
+	dirs := make([]di.Direction, len(text))
+	for i := range dirs {
+		dirs[i] = base
+	}
+
+	def := bidi.LeftToRight
+	if base == di.DirectionRTL {
+		def = bidi.RightToLeft
+	}
+	var p bidi.Paragraph
+	p.SetString(string(text), bidi.DefaultDirection(def))
+	order, err := p.Order()
+	if err != nil {
+		return dirs
+	}
+	for i := 0; i < order.NumRuns(); i++ {
+		run := order.Run(i)
+		dir := di.DirectionLTR
+		if run.Direction() == bidi.RightToLeft {
+			dir = di.DirectionRTL
+		}
+		start, end := run.Pos()
+		for k := start; k <= end && k < len(dirs); k++ {
+			dirs[k] = dir
+		}
+	}
+	return dirs
+}
+
+// bidiLevel returns the embedding level of text resolved to dir in a
+// paragraph of the base direction: right-to-left text sits one level above
+// a left-to-right paragraph, left-to-right text (e.g. numbers) one above a
+// right-to-left one
+// The bidi package only reports directions; without explicit embeddings,
+// which labels do not use, the direction fixes the level
+func bidiLevel(dir, base di.Direction) int {
+	switch {
+	case dir == di.DirectionRTL:
+		return 1
+	case base == di.DirectionRTL:
+		return 2
+	}
+	return 0
+}
+
+// shapeRuns shapes runes start to end of text, resolved to direction dir in
+// a paragraph of the base direction, and returns its runs in logical order;
+// reorderRuns puts a line's runs in visual order
+// The rest of text is context for shaping, e.g. for Arabic joining
+func (s *textShaper) shapeRuns(text []rune, start, end int, dir, base di.Direction) []shapedRun {
+	input := shaping.Input{
+		Text:      text,
+		RunStart:  start,
+		RunEnd:    end,
+		Direction: dir,
+		Face:      s.fonts[0].shaping,
+		Size:      s.size,
+	}
+
+	var runs []shapedRun
+	for _, in := range s.seg.Split(input, s) {
+		out := s.shaper.Shape(in)
+		cf := s.chainFont(out.Face)
+
+		extra := fixed.I(cf.bold)
+		for i := range out.Glyphs {
+			g := &out.Glyphs[i]
+			// Synthetic bold widens every glyph; letter spacing follows every
+			// character (cluster), not every glyph of a ligature
+			if g.XAdvance != 0 {
+				g.XAdvance += extra
+			}
+			if i == len(out.Glyphs)-1 || out.Glyphs[i+1].ClusterIndex != g.ClusterIndex {
+				g.XAdvance += s.track * fixed.Int26_6(g.RuneCount)
+			}
+
+			// Whole-pixel advances and offsets, as font.HintingFull gave
+			// before text was shaped, so every copy of a letter lands on the
+			// pixel grid the same way and thin strokes do not blur away at
+			// the printer's threshold
+			g.XAdvance = fixed.I(g.XAdvance.Round())
+			g.XOffset = fixed.I(g.XOffset.Round())
+			g.YOffset = fixed.I(g.YOffset.Round())
+		}
+		out.RecomputeAdvance()
+
+		level := bidiLevel(in.Direction, base)
+		runs = append(runs, shapedRun{out: out, font: cf, level: level, shaper: s, text: text})
+	}
+	return runs
+}
+
+// reorderRuns puts runs in visual order (rule L2 of the bidi algorithm):
+// from the highest level down to the lowest odd level, reverse every
+// sequence of runs at that level or above
+func reorderRuns(runs []shapedRun) {
+	highest, lowestOdd := 0, 3
+	for _, r := range runs {
+		highest = max(highest, r.level)
+		if r.level%2 == 1 {
+			lowestOdd = min(lowestOdd, r.level)
+		}
+	}
+
+	for level := highest; level >= lowestOdd; level-- {
+		for i := 0; i < len(runs); {
+			if runs[i].level < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(runs) && runs[j].level >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				runs[a], runs[b] = runs[b], runs[a]
+			}
+			i = j
+		}
+	}
+}
+
+// drawGlyph rasterizes a glyph outline with its origin at dot, rounded to
+// a whole pixel; the outline itself is not hinted, as sfnt does not support it
+func (s *textShaper) drawGlyph(dst draw.Image, src image.Image, f *chainFont, gid sfnt.GlyphIndex, dot fixed.Point26_6) {
+	dot = fixed.P(dot.X.Round(), dot.Y.Round())
+	segments, err := f.outlines.LoadGlyph(&s.buf, gid, s.size, nil)
+	if err != nil || len(segments) == 0 {
+		return
+	}
+
+	// Pixel box of the glyph at the dot, and the offset into it
+	b := segments.Bounds().Add(dot)
+	dr := image.Rect(b.Min.X.Floor(), b.Min.Y.Floor(), b.Max.X.Ceil(), b.Max.Y.Ceil())
+	if dr.Empty() {
+		return
+	}
+	biasX := dot.X - fixed.I(dr.Min.X)
+	biasY := dot.Y - fixed.I(dr.Min.Y)
+	pt := func(p fixed.Point26_6) (float32, float32) {
+		return float32(p.X+biasX) / 64, float32(p.Y+biasY) / 64
+	}
+
+	s.rast.Reset(dr.Dx(), dr.Dy())
+	s.rast.DrawOp = draw.Src
+	for _, seg := range segments {
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			s.rast.MoveTo(pt(seg.Args[0]))
+		case sfnt.SegmentOpLineTo:
+			s.rast.LineTo(pt(seg.Args[0]))
+		case sfnt.SegmentOpQuadTo:
+			x1, y1 := pt(seg.Args[0])
+			x2, y2 := pt(seg.Args[1])
+			s.rast.QuadTo(x1, y1, x2, y2)
+		case sfnt.SegmentOpCubeTo:
+			x1, y1 := pt(seg.Args[0])
+			x2, y2 := pt(seg.Args[1])
+			x3, y3 := pt(seg.Args[2])
+			s.rast.CubeTo(x1, y1, x2, y2, x3, y3)
+		}
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, dr.Dx(), dr.Dy()))
+	s.rast.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	if f.bold > 0 {
+		mask = embolden(mask, f.bold)
+		dr.Max.X += f.bold
+	}
+
+	draw.DrawMask(dst, dr, src, image.Point{}, mask, image.Point{}, draw.Over)
+}
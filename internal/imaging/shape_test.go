@@ -0,0 +1,113 @@
+package imaging
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+var registerTestFont sync.Once
+
+// testFontLayout returns a layout in Roboto, from testdata; the embedded Go
+// fonts have no kerning or ligatures
+func testFontLayout(t *testing.T, opts TextOptions) *textLayout {
+	t.Helper()
+	registerTestFont.Do(func() {
+		data, err := os.ReadFile(filepath.Join("testdata", "Roboto-Regular.ttf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		Fonts.RegisterFont(FontInfo{Name: "Roboto Regular", Family: "Roboto", Style: "Regular"}, data)
+	})
+	opts.FontFamily = "Roboto"
+	layout, err := newTextLayout(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layout
+}
+
+// plain returns text in the label's style
+func plain(text string) styledText {
+	return newStyledText([]Span{{Text: text}})
+}
+
+func TestShapeKerning(t *testing.T) {
+	layout := testFontLayout(t, TextOptions{FontSize: 36})
+	for _, pair := range []string{"AV", "To"} {
+		kerned := layout.measure(plain(pair))
+		apart := layout.measure(plain(pair[:1])) + layout.measure(plain(pair[1:]))
+		if kerned >= apart {
+			t.Errorf("%s is %d pixels wide, want less than %d for its letters apart", pair, kerned, apart)
+		}
+	}
+}
+
+func TestShapeLigature(t *testing.T) {
+	layout := testFontLayout(t, TextOptions{FontSize: 36})
+	runs := layout.shape(plain("fi"))
+	if len(runs) != 1 || len(runs[0].out.Glyphs) != 1 {
+		t.Fatalf("fi shaped to %d runs, want one run of one ligature glyph", len(runs))
+	}
+	if g := runs[0].out.Glyphs[0]; g.RuneCount != 2 {
+		t.Errorf("ligature covers %d characters, want 2", g.RuneCount)
+	}
+}
+
+// visualClusters returns the clusters of a line's glyphs in visual order
+func visualClusters(layout *textLayout, line styledText) []int {
+	var clusters []int
+	for _, run := range layout.shape(line) {
+		for _, g := range run.out.Glyphs {
+			clusters = append(clusters, g.ClusterIndex)
+		}
+	}
+	return clusters
+}
+
+func TestShapeBidiOrder(t *testing.T) {
+	// Missing Hebrew glyphs still shape to .notdef glyphs in order
+	layout, err := newTextLayout(TextOptions{FontSize: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		line styledText
+		want []int
+	}{
+		{"left to right", plain("abc"), []int{0, 1, 2}},
+		{"right to left", plain("שלום"), []int{3, 2, 1, 0}},
+		{"rtl word in ltr text", plain("abc שלום def"), []int{0, 1, 2, 3, 7, 6, 5, 4, 8, 9, 10, 11}},
+		// Numbers after Latin text stay with it (rule W7)
+		{"ltr words in rtl text", plain("שלום abc 123"), []int{5, 6, 7, 8, 9, 10, 11, 4, 3, 2, 1, 0}},
+		{"number in rtl text", plain("עד 25 שקל"), []int{8, 7, 6, 5, 3, 4, 2, 1, 0}},
+		// Neutrals in their own style take the direction around them
+		{"styled neutral", newStyledText([]Span{{Text: "שלום"}, {Text: " - ", Style: SpanStyle{Bold: true}}, {Text: "עולם"}}),
+			[]int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0}},
+	}
+	for _, tt := range tests {
+		if got := visualClusters(layout, tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: clusters %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShapeWholePixelAdvances(t *testing.T) {
+	layout, err := newTextLayout(TextOptions{FontSize: 9.5, LetterSpacing: 0.3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, run := range layout.shape(plain("Hinted text, AV 123")) {
+		for _, g := range run.out.Glyphs {
+			if g.XAdvance%fixed.I(1) != 0 || g.XOffset%fixed.I(1) != 0 {
+				t.Fatalf("glyph %d has advance %v and offset %v, want whole pixels", g.GlyphID, g.XAdvance, g.XOffset)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomedium"
+	"golang.org/x/image/font/gofont/gomediumitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/gosmallcaps"
+	"golang.org/x/image/font/gofont/gosmallcapsitalic"
+)
+
+// condensedScale is the width of condensed text relative to normal text
+const condensedScale = 0.8
+
+// registerGoFonts adds the bundled Go font family variants
+func registerGoFonts(r *FontRegistry) {
+	fonts := []struct {
+		family, style string
+		data          []byte
+	}{
+		{"Go", "Bold", gobold.TTF},
+		{"Go", "Italic", goitalic.TTF},
+		{"Go", "Bold Italic", gobolditalic.TTF},
+		{"Go", "Medium", gomedium.TTF},
+		{"Go", "Medium Italic", gomediumitalic.TTF},
+		{"Go Mono", "Regular", gomono.TTF},
+		{"Go Mono", "Bold", gomonobold.TTF},
+		{"Go Mono", "Italic", gomonoitalic.TTF},
+		{"Go Mono", "Bold Italic", gomonobolditalic.TTF},
+		{"Go Smallcaps", "Regular", gosmallcaps.TTF},
+		{"Go Smallcaps", "Italic", gosmallcapsitalic.TTF},
+	}
+	for _, f := range fonts {
+		r.RegisterFont(FontInfo{Name: f.family + " " + f.style, Family: f.family, Style: f.style}, f.data)
+	}
+}
+
+// styleCandidates lists the style names to try for a bold and/or italic font
+func styleCandidates(base string, bold, italic bool) []string {
+	switch {
+	case bold && italic:
+		return []string{"Bold Italic", "Bold Oblique", "Bold", "Italic", "Oblique"}
+	case bold:
+		return []string{"Bold"}
+	case italic:
+		return []string{"Italic", "Oblique"}
+	}
+	return []string{base}
+}
+
+// isBoldStyle reports whether a style name is already bold or heavier
+func isBoldStyle(style string) bool {
+	s := strings.ToLower(style)
+	for _, w := range []string{"bold", "black", "heavy"} {
+		if strings.Contains(s, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// emboldenStrength is the synthetic bold stroke width for a face of the
+// given pixel size, about 1/24 em
+func emboldenStrength(size, dpi float64) int {
+	return max(1, int(math.Round(size*dpi/72/24)))
+}
+
+// embolden thickens a glyph mask for fonts without a bold style by ORing it
+// with itself shifted right up to strength pixels; the result is wider by strength
+func embolden(mask *image.Alpha, strength int) *image.Alpha {
+	b := mask.Bounds()
+	bold := image.NewAlpha(image.Rect(0, 0, b.Dx()+strength, b.Dy()))
+	for s := 0; s <= strength; s++ {
+		draw.Draw(bold, image.Rect(s, 0, s+b.Dx(), b.Dy()), mask, b.Min, draw.Over)
+	}
+	return bold
+}
+
+// condense squeezes an image horizontally to width pixels
+func condense(src *image.RGBA, width int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, src.Bounds().Dy()))
+	xdraw.BiLinear.Scale(dst, dst.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+	return dst
+}
@@ -4,31 +4,113 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"math"
 	"strings"
 	"unicode"
 
-	"github.com/golang/freetype"
-	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/gofont/goregular"
 	"golang.org/x/image/math/fixed"
 )
 
+// Orientation is which way text runs on the label
 type Orientation int
 
 const (
-	Horizontal Orientation = iota
-	Vertical
+	Horizontal  Orientation = iota
+	Vertical                // turned 90 degrees clockwise
+	UpsideDown              // turned 180 degrees
+	VerticalCCW             // turned 90 degrees counter-clockwise
 )
 
 // TextOptions configures text rendering
 type TextOptions struct {
 	FontSize      float64
 	Orientation   Orientation
-	Invert        bool // White text on black background
-	WordBreakOnly bool // Only break lines on spaces, not mid-word
+	Invert        bool   // White text on black background
+	WordBreakOnly bool   // Only break lines between words (Unicode line breaking rules), not mid-word
+	Hyphenate     string // with WordBreakOnly, hyphenate words in this language (e.g. "de"), "" for none
+	Balanced      bool   // with WordBreakOnly, even out line lengths instead of filling each line
+
+	FontFamily string // font family from Fonts, "" for the embedded Go font
+	FontStyle  string // style within the family, "" for Regular
+	Bold       bool   // use the family's bold style, or thicken the strokes
+	Italic     bool   // use the family's italic or oblique style
+	Condensed  bool   // squeeze the text horizontally
+
+	Fit         bool    // pick the largest font size at which the text fits the label
+	MinFontSize float64 // smallest size Fit may use, 0 for DefaultMinFontSize
+	MaxFontSize float64 // largest size Fit may use, 0 for DefaultMaxFontSize
+	ShrinkOnly  bool    // Fit only makes FontSize smaller, never larger
+
+	Align         HAlign
+	VAlign        VAlign
+	Margins       *Margins // nil for the default 5 dot left and right margins
+	LineSpacing   float64  // multiple of the font's line height, 0 for 1
+	LetterSpacing float64  // extra space between characters in dots (may be negative)
+
+	Markup bool // parse the text with ParseMarkup for bold, italic, sizes and icons
+
+	Underline bool     // underline all of the text
+	Strike    bool     // strike through all of the text
+	Outline   int      // draw the text hollow, outlined this many dots wide; 0 for solid
+	Highlight bool     // print each line inverted, on a bar as long as the line
+	Box       *TextBox // draw a box around the text, nil for none
 }
 
+// HAlign is the horizontal alignment of lines of text
+type HAlign int
+
+const (
+	AlignCenter HAlign = iota
+	AlignLeft
+	AlignRight
+	AlignJustify // stretch all but the last line of a paragraph to the full width
+)
+
+// VAlign is the vertical position of the text block
+type VAlign int
+
+const (
+	VAlignMiddle VAlign = iota
+	VAlignTop
+	VAlignBottom
+)
+
+// Margins is the space in dots kept clear on each side of the text, in the
+// text's reading direction (before a vertical label is rotated)
+type Margins struct {
+	Top, Right, Bottom, Left int
+}
+
+// defaultMargins leaves 5 dots either side of each line
+var defaultMargins = Margins{Right: 5, Left: 5}
+
+// margins returns the space kept clear around the text: the options'
+// margins, or the default margins, plus room for the box and outline
+func (opts TextOptions) margins() Margins {
+	m := defaultMargins
+	if opts.Margins != nil {
+		m = *opts.Margins
+	}
+	inset := opts.effectInset()
+	return Margins{Top: m.Top + inset, Right: m.Right + inset, Bottom: m.Bottom + inset, Left: m.Left + inset}
+}
+
+// lineHeight returns the distance between baselines in pixels
+func (opts TextOptions) lineHeight(metrics font.Metrics) int {
+	spacing := opts.LineSpacing
+	if spacing <= 0 {
+		spacing = 1
+	}
+	return int(math.Round(float64(metrics.Height.Ceil()) * spacing))
+}
+
+// Font size bounds for fitting text, matching the Font Size slider
+const (
+	DefaultMinFontSize = 4.0
+	DefaultMaxFontSize = 72.0
+)
+
 // RenderText creates an image from text (legacy wrapper)
 func RenderText(text string, width, height int, fontSize float64, orientation Orientation) (image.Image, error) {
 	return RenderTextWithOptions(text, width, height, TextOptions{
@@ -39,18 +121,36 @@ func RenderText(text string, width, height int, fontSize float64, orientation Or
 
 // RenderTextWithOptions creates an image from text with full options
 func RenderTextWithOptions(text string, width, height int, opts TextOptions) (image.Image, error) {
-	// Load font
-	f, err := truetype.Parse(goregular.TTF)
+	if opts.Fit {
+		size, err := FitFontSize(text, width, height, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.FontSize = size
+	}
+
+	// Fonts come from the registry, so typing does not re-parse them
+	layout, err := newTextLayout(opts)
 	if err != nil {
 		return nil, err
 	}
 
 	// For vertical, we swap dimensions for initial render, then rotate
 	renderW, renderH := width, height
-	if opts.Orientation == Vertical {
+	if opts.Orientation.Sideways() {
 		renderW, renderH = height, width
 	}
 
+	// Condensed text is laid out on a wider canvas and squeezed afterwards;
+	// margins are given on the label, so they are widened too
+	m := opts.margins()
+	finalW := renderW
+	boxW := opts.layoutWidth(renderW - m.Left - m.Right)
+	left := opts.layoutWidth(m.Left)
+	if opts.Condensed {
+		renderW = opts.layoutWidth(renderW)
+	}
+
 	// Set colors based on invert option
 	bgColor := color.White
 	fgColor := color.Black
@@ -63,143 +163,287 @@ func RenderTextWithOptions(text string, width, height int, opts TextOptions) (im
 	img := image.NewRGBA(image.Rect(0, 0, renderW, renderH))
 	draw.Draw(img, img.Bounds(), &image.Uniform{bgColor}, image.Point{}, draw.Src)
 
-	// Set up freetype context
-	c := freetype.NewContext()
-	c.SetDPI(203) // Match printer DPI
-	c.SetFont(f)
-	c.SetFontSize(opts.FontSize)
-	c.SetClip(img.Bounds())
-	c.SetDst(img)
-	c.SetSrc(&image.Uniform{fgColor})
-	c.SetHinting(font.HintingFull)
-
-	// Calculate text position (centered vertically)
-	face := truetype.NewFace(f, &truetype.Options{Size: opts.FontSize, DPI: 203})
-	metrics := face.Metrics()
-	textHeight := metrics.Ascent.Ceil()
-
-	// Word wrap and draw
-	var lines []string
-	if opts.WordBreakOnly {
-		lines = wrapTextWordOnly(text, face, renderW-10)
-	} else {
-		lines = wrapText(text, face, renderW-10)
-	}
-	y := (renderH-len(lines)*int(metrics.Height.Ceil()))/2 + textHeight
+	fg := &image.Uniform{fgColor}
+	bg := &image.Uniform{bgColor}
+
+	// Text is drawn into a mask first, so effects can reshape and recolour it
+	mask := image.NewAlpha(img.Bounds())
+	var extent image.Rectangle
+	var bars []image.Rectangle
+
+	// Word wrap and position the block inside the margins
+	styled := newStyledText(opts.spans(text))
+	lines := layoutLines(styled, layout, boxW, opts)
+
+	blockH := layout.blockHeight(lines)
+	top := m.Top + (renderH-m.Top-m.Bottom-blockH)/2
+	switch opts.VAlign {
+	case VAlignTop:
+		top = m.Top
+	case VAlignBottom:
+		top = renderH - m.Bottom - blockH
+	}
 
 	for _, line := range lines {
-		// Center each line horizontally
-		lineWidth := measureString(face, line)
-		x := (renderW - lineWidth) / 2
+		// Lines are as tall as their largest text
+		metrics := layout.metrics(line.text)
+		y := top + metrics.Ascent.Ceil()
+		lineWidth := layout.measure(line.text)
+
+		var x int
+		var wordSpace fixed.Int26_6
+		drawnWidth := lineWidth
+		switch opts.Align {
+		case AlignLeft:
+			x = left
+		case AlignRight:
+			x = left + boxW - lineWidth
+		case AlignJustify:
+			x = left
+			if spaces := line.text.spaces(); !line.last && spaces > 0 && lineWidth < boxW {
+				wordSpace = fixed.I(boxW-lineWidth) / fixed.Int26_6(spaces)
+				drawnWidth = boxW
+			}
+		default:
+			x = left + (boxW-lineWidth)/2
+		}
+
+		layout.draw(mask, image.Opaque, x, y, line.text, wordSpace)
+
+		r := image.Rect(x, top, x+drawnWidth, top+metrics.Height.Ceil())
+		if drawnWidth > 0 {
+			extent = extent.Union(r)
+			pad := highlightPad(metrics)
+			bars = append(bars, image.Rect(r.Min.X-pad, r.Min.Y, r.Max.X+pad, r.Max.Y))
+		}
+		top += opts.lineHeight(metrics)
+	}
+
+	if opts.Outline > 0 {
+		mask = outlineMask(mask, opts.Outline)
+	}
+	draw.DrawMask(img, img.Bounds(), fg, image.Point{}, mask, image.Point{}, draw.Over)
+	if opts.Highlight {
+		for _, bar := range bars {
+			draw.Draw(img, bar, fg, image.Point{}, draw.Src)
+			draw.DrawMask(img, bar, bg, image.Point{}, mask, bar.Min, draw.Over)
+		}
+	}
 
-		pt := freetype.Pt(x, y)
-		c.DrawString(line, pt)
-		y += int(metrics.Height.Ceil())
+	if opts.Condensed {
+		img = condense(img, finalW)
+		extent.Min.X = int(float64(extent.Min.X) * condensedScale)
+		extent.Max.X = int(math.Ceil(float64(extent.Max.X) * condensedScale))
 	}
 
-	// Rotate if vertical
-	if opts.Orientation == Vertical {
-		return rotate90CW(img), nil
+	// The box goes on after condensing so its sides keep their width
+	if opts.Box != nil && !extent.Empty() {
+		grow := opts.Box.Padding + opts.Box.lineWidth()
+		r := extent.Inset(-grow).Intersect(img.Bounds())
+		drawBox(img, fg, r, opts.Box.lineWidth(), opts.Box.Radius)
 	}
 
-	return img, nil
+	return opts.Orientation.orient(img), nil
 }
 
-// wrapText splits text into lines that fit within maxWidth (breaks anywhere)
-func wrapText(text string, face font.Face, maxWidth int) []string {
-	var lines []string
-	var currentLine string
+// FitFontSize returns the largest font size at which the wrapped text fits
+// a width x height label (swapped for vertical text) within the options'
+// size bounds; if even the smallest size overflows, that size is returned
+func FitFontSize(text string, width, height int, opts TextOptions) (float64, error) {
+	lo, hi := opts.MinFontSize, opts.MaxFontSize
+	if lo <= 0 {
+		lo = DefaultMinFontSize
+	}
+	if hi <= 0 {
+		hi = DefaultMaxFontSize
+	}
+	if opts.ShrinkOnly && opts.FontSize > 0 {
+		hi = math.Min(hi, opts.FontSize)
+	}
+	if hi < lo {
+		hi = lo
+	}
 
-	for _, char := range text {
-		if char == '\n' {
-			lines = append(lines, currentLine)
-			currentLine = ""
-			continue
+	renderW, renderH := width, height
+	if opts.Orientation.Sideways() {
+		renderW, renderH = height, width
+	}
+	m := opts.margins()
+	boxW, boxH := renderW-m.Left-m.Right, renderH-m.Top-m.Bottom
+
+	// One layout is resized for each try, so fonts and shapers load once
+	opts.FontSize = hi
+	layout, err := newTextLayout(opts)
+	if err != nil {
+		return 0, err
+	}
+	styled := newStyledText(opts.spans(text))
+	fits := func(size float64) (bool, error) {
+		if err := layout.setSize(size); err != nil {
+			return false, err
 		}
-		testLine := currentLine + string(char)
-		if measureString(face, testLine) > maxWidth && currentLine != "" {
-			lines = append(lines, currentLine)
-			currentLine = string(char)
+		w, h := layout.measureBlock(styled, boxW)
+		return w <= boxW && h <= boxH, nil
+	}
+
+	if ok, err := fits(hi); ok || err != nil {
+		return hi, err
+	}
+
+	// Binary search over half points from lo up to hi, which is too big:
+	// half point a fits (or is below lo) and b does not
+	a, b := int(math.Ceil(lo*2))-1, int(math.Ceil(hi*2))
+	for b-a > 1 {
+		mid := (a + b) / 2
+		ok, err := fits(float64(mid) / 2)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			a = mid
 		} else {
-			currentLine = testLine
+			b = mid
 		}
 	}
+	return math.Max(lo, float64(a)/2), nil
+}
 
-	if currentLine != "" {
-		lines = append(lines, currentLine)
+// registryFont returns the registry name of the family's font in the
+// options' style and whether its strokes need thickening to look bold
+func (opts TextOptions) registryFont(family string) (string, bool) {
+	name := ""
+	for _, style := range styleCandidates(opts.FontStyle, opts.Bold, opts.Italic) {
+		n, ok := Fonts.Lookup(family, style)
+		if info, _ := Fonts.Info(n); ok && strings.EqualFold(info.Style, style) {
+			name = n
+			break
+		}
+	}
+	if name == "" {
+		var ok bool
+		if name, ok = Fonts.Lookup(family, opts.FontStyle); !ok {
+			return "", false
+		}
 	}
 
-	return lines
+	info, _ := Fonts.Info(name)
+	return name, opts.Bold && !isBoldStyle(info.Style)
 }
 
-// wrapTextWordOnly splits text into lines, only breaking at word boundaries
-func wrapTextWordOnly(text string, face font.Face, maxWidth int) []string {
-	var lines []string
+// chainEntry is a font of the fallback chain by registry name
+type chainEntry struct {
+	name      string
+	synthBold bool
+}
 
-	// First split by explicit newlines
-	paragraphs := strings.Split(text, "\n")
+// fontChain returns the options' font followed by the installed fallback fonts
+// Chains are cached by the registry, as resolving one looks up every family
+func (opts TextOptions) fontChain() []chainEntry {
+	family := opts.FontFamily
+	if family == "" {
+		family = "Go"
+	}
+	key := chainKey{family: family, style: opts.FontStyle, bold: opts.Bold, italic: opts.Italic}
+	chain, gen, ok := Fonts.cachedChain(key)
+	if ok {
+		return chain
+	}
 
-	for _, para := range paragraphs {
-		words := strings.Fields(para)
-		if len(words) == 0 {
-			lines = append(lines, "")
+	used := make(map[string]bool)
+	for _, fam := range append([]string{family}, FallbackFamilies...) {
+		name, synthBold := opts.registryFont(fam)
+		if name == "" || used[name] {
 			continue
 		}
+		used[name] = true
+		chain = append(chain, chainEntry{name: name, synthBold: synthBold})
+	}
+	Fonts.cacheChain(key, gen, chain)
+	return chain
+}
 
-		currentLine := words[0]
-		for i := 1; i < len(words); i++ {
-			word := words[i]
-			testLine := currentLine + " " + word
-
-			if measureString(face, testLine) > maxWidth {
-				// Current line is full, start new line
-				lines = append(lines, currentLine)
-
-				// Check if single word is too long
-				if measureString(face, word) > maxWidth {
-					// Word itself is too long, we need to break it
-					currentLine = breakLongWord(word, face, maxWidth, &lines)
-				} else {
-					currentLine = word
-				}
-			} else {
-				currentLine = testLine
-			}
-		}
+// layoutWidth converts a width on the label to the width text is laid out in
+func (opts TextOptions) layoutWidth(w int) int {
+	if opts.Condensed {
+		return int(float64(w) / condensedScale)
+	}
+	return w
+}
 
-		if currentLine != "" {
-			lines = append(lines, currentLine)
+// measureTextBlock returns the size of text wrapped to maxWidth the same way
+// RenderTextWithOptions wraps it
+func measureTextBlock(text string, maxWidth int, opts TextOptions) (int, int, error) {
+	layout, err := newTextLayout(opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	w, h := layout.measureBlock(newStyledText(opts.spans(text)), maxWidth)
+	return w, h, nil
+}
+
+// measureBlock returns the size of styled text wrapped to maxWidth
+func (l *textLayout) measureBlock(styled styledText, maxWidth int) (int, int) {
+	lines := layoutLines(styled, l, l.opts.layoutWidth(maxWidth), l.opts)
+
+	w := 0
+	for _, line := range lines {
+		if lw := l.measure(line.text); lw > w {
+			w = lw
 		}
 	}
+	if l.opts.Condensed {
+		w = int(math.Ceil(float64(w) * condensedScale))
+	}
+	return w, l.blockHeight(lines)
+}
 
-	return lines
+// textLine is a wrapped line of text
+type textLine struct {
+	text styledText
+	last bool // ends its paragraph, so it is never justified
 }
 
-// breakLongWord breaks a single word that's too long to fit
-func breakLongWord(word string, face font.Face, maxWidth int, lines *[]string) string {
-	var currentPart string
-	for _, char := range word {
-		testPart := currentPart + string(char)
-		if measureString(face, testPart) > maxWidth && currentPart != "" {
-			*lines = append(*lines, currentPart)
-			currentPart = string(char)
+// layoutLines wraps each paragraph of text to maxWidth
+func layoutLines(text styledText, m lineMeasurer, maxWidth int, opts TextOptions) []textLine {
+	breaker := opts.newLineBreaker(m, maxWidth)
+	var lines []textLine
+	for _, para := range text.split('\n') {
+		var wrapped []styledText
+		if opts.WordBreakOnly {
+			wrapped = breaker.wrap(para)
 		} else {
-			currentPart = testPart
+			wrapped = wrapText(para, m, maxWidth)
+		}
+		if len(wrapped) == 0 {
+			wrapped = []styledText{{}}
+		}
+		for i, l := range wrapped {
+			lines = append(lines, textLine{text: l, last: i == len(wrapped)-1})
 		}
 	}
-	return currentPart
+	return lines
 }
 
-// measureString returns the width of a string in pixels
-func measureString(face font.Face, s string) int {
-	var width fixed.Int26_6
-	for _, r := range s {
-		adv, ok := face.GlyphAdvance(r)
-		if ok {
-			width += adv
+// wrapText splits a paragraph into lines that fit within maxWidth (breaks anywhere)
+// The paragraph is shaped once and lines are filled by summing advances;
+// each line is then measured on its own and shortened in case kerning or
+// joining across its end made the sum too small
+func wrapText(text styledText, m lineMeasurer, maxWidth int) []styledText {
+	pen := prefixSums(m.advances(text))
+	var lines []styledText
+	start := 0
+	for start < len(text.text) {
+		end := start + 1
+		for end < len(text.text) && (pen[end+1]-pen[start]).Ceil() <= maxWidth {
+			end++
+		}
+		for end > start+1 && m.measure(text.slice(start, end)) > maxWidth {
+			end--
 		}
+		lines = append(lines, text.slice(start, end))
+		start = end
 	}
-	return width.Ceil()
+	return lines
 }
 
 // rotate90CW rotates an image 90 degrees clockwise
@@ -208,12 +452,7 @@ func rotate90CW(src image.Image) image.Image {
 	w, h := bounds.Dx(), bounds.Dy()
 
 	dst := image.NewRGBA(image.Rect(0, 0, h, w))
-
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			dst.Set(h-1-y, x, src.At(bounds.Min.X+x, bounds.Min.Y+y))
-		}
-	}
+	copyRGBA(dst, src, image.Pt(h-1, 0), image.Pt(0, 1), image.Pt(-1, 0))
 
 	return dst
 }
@@ -224,20 +463,21 @@ func rotate90CCW(src image.Image) image.Image {
 	w, h := bounds.Dx(), bounds.Dy()
 
 	dst := image.NewRGBA(image.Rect(0, 0, h, w))
-
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			dst.Set(y, w-1-x, src.At(bounds.Min.X+x, bounds.Min.Y+y))
-		}
-	}
+	copyRGBA(dst, src, image.Pt(0, w-1), image.Pt(0, -1), image.Pt(1, 0))
 
 	return dst
 }
 
-// RotatePreviewForDisplay rotates a vertical-orientation image for on-screen display
-// so the text reads correctly (counter-clockwise)
-func RotatePreviewForDisplay(img image.Image) image.Image {
-	return rotate90CCW(img)
+// RotatePreviewForDisplay turns a sideways label back for on-screen display
+// so the text reads correctly; other orientations are shown as printed
+func RotatePreviewForDisplay(img image.Image, o Orientation) image.Image {
+	switch o {
+	case Vertical:
+		return rotate90CCW(img)
+	case VerticalCCW:
+		return rotate90CW(img)
+	}
+	return img
 }
 
 // IsWhitespace checks if a rune is whitespace
@@ -0,0 +1,94 @@
+package imaging
+
+import (
+	"testing"
+)
+
+func TestTextLayoutSetSize(t *testing.T) {
+	text := "Plain **bold** *italic* [size=150%]big[/size] text that wraps"
+	opts := TextOptions{FontSize: 30, Markup: true, WordBreakOnly: true}
+	layout, err := newTextLayout(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	styled := newStyledText(opts.spans(text))
+	layout.measureBlock(styled, 300) // load every style's shaper
+
+	// A resized layout measures what a new one at that size does
+	for _, size := range []float64{8, 17.5, 30, 44} {
+		if err := layout.setSize(size); err != nil {
+			t.Fatal(err)
+		}
+		gotW, gotH := layout.measureBlock(styled, 300)
+
+		opts.FontSize = size
+		wantW, wantH, err := measureTextBlock(text, 300, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotW != wantW || gotH != wantH {
+			t.Errorf("size %g: resized layout measures %dx%d, new layout %dx%d", size, gotW, gotH, wantW, wantH)
+		}
+	}
+}
+
+func TestFitFontSize(t *testing.T) {
+	const long = "A rather long sentence that cannot fit on a tiny label at any size"
+	tests := []struct {
+		name   string
+		text   string
+		width  int
+		height int
+		opts   TextOptions
+		want   float64 // 0 to only check that the size fits and is the largest
+	}{
+		{name: "fits", text: "Hello label world", width: 300, height: 96, opts: TextOptions{FontSize: 12}},
+		{name: "wraps", text: "Hello label world", width: 120, height: 200, opts: TextOptions{FontSize: 12, WordBreakOnly: true}},
+		{name: "sideways", text: "Side", width: 96, height: 300, opts: TextOptions{FontSize: 12, Orientation: Vertical}},
+		{name: "max", text: "a", width: 384, height: 384, opts: TextOptions{MaxFontSize: 20}, want: 20},
+		{name: "default max", text: "a", width: 2000, height: 2000, want: DefaultMaxFontSize},
+		{name: "min", text: long, width: 40, height: 20, opts: TextOptions{MinFontSize: 6}, want: 6},
+		{name: "default min", text: long, width: 40, height: 20, want: DefaultMinFontSize},
+		{name: "shrink only keeps size", text: "a", width: 384, height: 384, opts: TextOptions{FontSize: 10, ShrinkOnly: true}, want: 10},
+		{name: "shrink only shrinks", text: "Hello label world", width: 120, height: 40, opts: TextOptions{FontSize: 40, ShrinkOnly: true}},
+		{name: "min above max", text: "a", width: 384, height: 384, opts: TextOptions{MinFontSize: 30, MaxFontSize: 20}, want: 30},
+	}
+
+	for _, tt := range tests {
+		got, err := FitFontSize(tt.text, tt.width, tt.height, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tt.want != 0 {
+			if got != tt.want {
+				t.Errorf("%s: size %g, want %g", tt.name, got, tt.want)
+			}
+			continue
+		}
+
+		if tt.opts.ShrinkOnly && got > tt.opts.FontSize {
+			t.Errorf("%s: size %g grew past %g", tt.name, got, tt.opts.FontSize)
+		}
+		w, h := tt.width, tt.height
+		if tt.opts.Orientation.Sideways() {
+			w, h = h, w
+		}
+		m := tt.opts.margins()
+		w, h = w-m.Left-m.Right, h-m.Top-m.Bottom
+		fits := func(size float64) bool {
+			opts := tt.opts
+			opts.FontSize = size
+			bw, bh, err := measureTextBlock(tt.text, w, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return bw <= w && bh <= h
+		}
+		if !fits(got) {
+			t.Errorf("%s: text does not fit at the chosen size %g", tt.name, got)
+		}
+		if fits(got + 0.5) {
+			t.Errorf("%s: text also fits at %g, larger than the chosen %g", tt.name, got+0.5, got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// Sideways reports whether the orientation turns text a quarter turn, so it
+// runs across the label
+func (o Orientation) Sideways() bool {
+	return o == Vertical || o == VerticalCCW
+}
+
+// orient turns an image rendered horizontally to the orientation
+func (o Orientation) orient(img image.Image) image.Image {
+	switch o {
+	case Vertical:
+		return rotate90CW(img)
+	case UpsideDown:
+		return rotate180(img)
+	case VerticalCCW:
+		return rotate90CCW(img)
+	}
+	return img
+}
+
+// rotate180 turns an image upside down
+func rotate180(src image.Image) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	copyRGBA(dst, src, image.Pt(w-1, h-1), image.Pt(-1, 0), image.Pt(0, -1))
+
+	return dst
+}
+
+// mirror flips an image left to right if horizontal, otherwise top to bottom
+func mirror(src image.Image, horizontal bool) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if horizontal {
+		copyRGBA(dst, src, image.Pt(w-1, 0), image.Pt(-1, 0), image.Pt(0, 1))
+	} else {
+		copyRGBA(dst, src, image.Pt(0, h-1), image.Pt(1, 0), image.Pt(0, -1))
+	}
+
+	return dst
+}
+
+// Transform rotates and mirrors an image before it is fitted to the label
+type Transform struct {
+	Angle   float64     // clockwise rotation in degrees
+	MirrorH bool        // flip left to right
+	MirrorV bool        // flip top to bottom
+	Fill    color.Color // the corners a rotation uncovers, nil for white
+}
+
+// IsIdentity reports whether the transform leaves images unchanged
+func (t Transform) IsIdentity() bool {
+	return !t.MirrorH && !t.MirrorV && normalizeAngle(t.Angle) == 0
+}
+
+// Apply mirrors img, then rotates it about its centre
+// Quarter turns move pixels exactly; other angles resample the image with
+// bilinear filtering, so edges stay smooth for the monochrome threshold,
+// onto a canvas just large enough to hold it
+func (t Transform) Apply(img image.Image) image.Image {
+	if t.MirrorH {
+		img = mirror(img, true)
+	}
+	if t.MirrorV {
+		img = mirror(img, false)
+	}
+
+	switch angle := normalizeAngle(t.Angle); angle {
+	case 0:
+		return img
+	case 90:
+		return rotate90CW(img)
+	case 180:
+		return rotate180(img)
+	case 270:
+		return rotate90CCW(img)
+	default:
+		return rotate(img, angle, t.Fill)
+	}
+}
+
+// normalizeAngle returns an angle in degrees within [0, 360), snapping
+// angles within a hundredth of a degree to a quarter turn
+func normalizeAngle(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	for _, q := range []float64{0, 90, 180, 270, 360} {
+		if math.Abs(deg-q) < 0.01 {
+			return math.Mod(q, 360)
+		}
+	}
+	return deg
+}
+
+// rotate turns an image clockwise by deg degrees, filling the uncovered
+// corners with fill (white if nil)
+func rotate(src image.Image, deg float64, fill color.Color) image.Image {
+	if fill == nil {
+		fill = color.White
+	}
+	b := src.Bounds()
+	sin, cos := math.Sincos(deg * math.Pi / 180)
+	w, h := float64(b.Dx()), float64(b.Dy())
+	dw := int(math.Ceil(math.Abs(w*cos) + math.Abs(h*sin)))
+	dh := int(math.Ceil(math.Abs(w*sin) + math.Abs(h*cos)))
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(fill), image.Point{}, draw.Src)
+
+	// Source to destination, y pointing down: turn about the source centre
+	// and move it to the destination centre
+	cx := float64(b.Min.X) + w/2
+	cy := float64(b.Min.Y) + h/2
+	m := f64.Aff3{
+		cos, -sin, float64(dw)/2 - (cos*cx - sin*cy),
+		sin, cos, float64(dh)/2 - (sin*cx + cos*cy),
+	}
+	xdraw.BiLinear.Transform(dst, m, src, b, xdraw.Over, nil)
+
+	return dst
+}
@@ -0,0 +1,145 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestNormalizeAngle(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{0, 0},
+		{90, 90},
+		{360, 0},
+		{-90, 270},
+		{450, 90},
+		{-720, 0},
+		{45, 45},
+		{-30, 330},
+		{89.995, 90},
+		{270.004, 270},
+		{359.996, 0},
+		{-0.004, 0},
+		{0.02, 0.02},
+	}
+	for _, tt := range tests {
+		if got := normalizeAngle(tt.in); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("normalizeAngle(%g) = %g, want %g", tt.in, got, tt.want)
+		}
+	}
+}
+
+// grayImage returns a w x h image whose pixels are numbered row by row
+func grayImage(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i + 1)
+	}
+	return img
+}
+
+// grayPixels returns an image's rows as grey levels
+func grayPixels(img image.Image) [][]uint8 {
+	b := img.Bounds()
+	rows := make([][]uint8, b.Dy())
+	for y := range rows {
+		rows[y] = make([]uint8, b.Dx())
+		for x := range rows[y] {
+			rows[y][x] = color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y
+		}
+	}
+	return rows
+}
+
+func TestTransformApplyExact(t *testing.T) {
+	// 1 2 3
+	// 4 5 6
+	src := grayImage(3, 2)
+	tests := []struct {
+		name string
+		t    Transform
+		want [][]uint8
+	}{
+		{"identity", Transform{}, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+		{"full turn", Transform{Angle: 360}, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+		{"90", Transform{Angle: 90}, [][]uint8{{4, 1}, {5, 2}, {6, 3}}},
+		{"180", Transform{Angle: 180}, [][]uint8{{6, 5, 4}, {3, 2, 1}}},
+		{"270", Transform{Angle: 270}, [][]uint8{{3, 6}, {2, 5}, {1, 4}}},
+		{"-90", Transform{Angle: -90}, [][]uint8{{3, 6}, {2, 5}, {1, 4}}},
+		{"nearly 90", Transform{Angle: 90.004}, [][]uint8{{4, 1}, {5, 2}, {6, 3}}},
+		{"mirror horizontal", Transform{MirrorH: true}, [][]uint8{{3, 2, 1}, {6, 5, 4}}},
+		{"mirror vertical", Transform{MirrorV: true}, [][]uint8{{4, 5, 6}, {1, 2, 3}}},
+		{"mirror both", Transform{MirrorH: true, MirrorV: true}, [][]uint8{{6, 5, 4}, {3, 2, 1}}},
+		// Mirrored first, then turned
+		{"mirror then 90", Transform{Angle: 90, MirrorH: true}, [][]uint8{{6, 3}, {5, 2}, {4, 1}}},
+	}
+	for _, tt := range tests {
+		got := grayPixels(tt.t.Apply(src))
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d rows, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for y := range got {
+			if string(got[y]) != string(tt.want[y]) {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestTransformApplyAngle(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 40, 20))
+	for i := range src.Pix {
+		src.Pix[i] = 0x80
+	}
+
+	for _, fill := range []color.Color{nil, color.White, color.Black} {
+		out := Transform{Angle: 30, Fill: fill}.Apply(src)
+
+		// The canvas just holds the turned image
+		b := out.Bounds()
+		wantW := int(math.Ceil(40*math.Cos(math.Pi/6) + 20*math.Sin(math.Pi/6)))
+		wantH := int(math.Ceil(40*math.Sin(math.Pi/6) + 20*math.Cos(math.Pi/6)))
+		if b.Dx() != wantW || b.Dy() != wantH {
+			t.Errorf("fill %v: canvas %dx%d, want %dx%d", fill, b.Dx(), b.Dy(), wantW, wantH)
+		}
+
+		want := color.Gray{0xFF}
+		if fill == color.Black {
+			want = color.Gray{0}
+		}
+		for _, p := range []image.Point{b.Min, {b.Max.X - 1, b.Min.Y}, {b.Min.X, b.Max.Y - 1}, b.Max.Sub(image.Pt(1, 1))} {
+			if got := color.GrayModel.Convert(out.At(p.X, p.Y)); got != want {
+				t.Errorf("fill %v: corner %v is %v, want %v", fill, p, got, want)
+			}
+		}
+		centre := color.GrayModel.Convert(out.At(b.Min.X+b.Dx()/2, b.Min.Y+b.Dy()/2)).(color.Gray)
+		if centre.Y < 0x7E || centre.Y > 0x82 {
+			t.Errorf("fill %v: centre is %v, want the image's grey", fill, centre)
+		}
+	}
+}
+
+func TestTransformIsIdentity(t *testing.T) {
+	tests := []struct {
+		t    Transform
+		want bool
+	}{
+		{Transform{}, true},
+		{Transform{Angle: 360}, true},
+		{Transform{Angle: -0.001}, true},
+		{Transform{Angle: 0.5}, false},
+		{Transform{Angle: 90}, false},
+		{Transform{MirrorH: true}, false},
+		{Transform{MirrorV: true, Angle: 360}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.t.IsIdentity(); got != tt.want {
+			t.Errorf("%+v: IsIdentity = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
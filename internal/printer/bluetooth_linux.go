@@ -244,22 +244,3 @@ func GetExistingRFCOMMConnections() ([]string, error) {
 
 	return devices, nil
 }
-
-// ListSerialPorts returns available serial ports (for manual connection)
-func ListSerialPorts() ([]string, error) {
-	var ports []string
-
-	// Check for rfcomm devices
-	rfcomm, _ := GetExistingRFCOMMConnections()
-	ports = append(ports, rfcomm...)
-
-	// Check common serial ports
-	commonPorts := []string{"/dev/ttyUSB0", "/dev/ttyUSB1", "/dev/ttyACM0", "/dev/ttyACM1"}
-	for _, p := range commonPorts {
-		if _, err := os.Stat(p); err == nil {
-			ports = append(ports, p)
-		}
-	}
-
-	return ports, nil
-}
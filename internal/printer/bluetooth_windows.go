@@ -4,7 +4,7 @@ package printer
 
 import (
 	"fmt"
-	"os"
+	"sort"
 	"strings"
 
 	"golang.org/x/sys/windows/registry"
@@ -141,33 +141,18 @@ func GetExistingRFCOMMConnections() ([]string, error) {
 
 // ListSerialPorts enumerates available COM ports on Windows
 func ListSerialPorts() ([]string, error) {
-	var ports []string
-
-	// Read from registry
-	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `HARDWARE\DEVICEMAP\SERIALCOMM`, registry.READ)
-	if err != nil {
-		// Fallback: check common COM ports
-		for i := 1; i <= 20; i++ {
-			port := fmt.Sprintf("COM%d", i)
-			// Try to check if port exists (this is a rough check)
-			ports = append(ports, port)
-		}
-		return ports[:4], nil // Return first 4 as fallback
-	}
-	defer key.Close()
-
-	names, err := key.ReadValueNames(-1)
+	drivers, err := serialCommDrivers()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, name := range names {
-		val, _, err := key.GetStringValue(name)
-		if err == nil {
-			ports = append(ports, val)
-		}
+	ports := make([]string, 0, len(drivers))
+	for port := range drivers {
+		ports = append(ports, port)
 	}
-
+	sort.Slice(ports, func(i, j int) bool {
+		return comPortNumber(ports[i]) < comPortNumber(ports[j])
+	})
 	return ports, nil
 }
 
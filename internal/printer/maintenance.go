@@ -0,0 +1,80 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"nelko-print/internal/tspl"
+)
+
+// runCommand sends a maintenance command if the printer model supports it
+func (p *Printer) runCommand(feature tspl.Feature, cmd *tspl.Command) error {
+	if p.port == nil {
+		return ErrNotConnected
+	}
+	if !p.Model().Supports(feature) {
+		return p.unsupported(strings.TrimSpace(cmd.String()))
+	}
+
+	if _, err := p.port.Write(cmd.Bytes()); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
+
+// Calibrate makes the printer measure the loaded media so the sensor finds
+// the start of each label again, e.g. after swapping rolls
+func (p *Printer) Calibrate(media tspl.MediaType) error {
+	cmd := tspl.New()
+	switch media {
+	case tspl.MediaBlackMark:
+		cmd.BLineDetect()
+	case tspl.MediaContinuous:
+		return errors.New("continuous media does not need calibration")
+	default:
+		cmd.GapDetect()
+	}
+	return p.runCommand(tspl.FeatureGapDetect, cmd)
+}
+
+// SelfTest prints the printer's self-test page
+func (p *Printer) SelfTest() error {
+	return p.runCommand(tspl.FeatureSelfTest, tspl.New().SelfTest())
+}
+
+// Home feeds to the start of the next label
+func (p *Printer) Home() error {
+	return p.runCommand(tspl.FeatureHome, tspl.New().Home())
+}
+
+// FormFeed feeds one blank label
+func (p *Printer) FormFeed() error {
+	return p.runCommand(tspl.FeatureHome, tspl.New().FormFeed())
+}
+
+// Initialize restores the printer's factory settings
+func (p *Printer) Initialize() error {
+	return p.runCommand(tspl.FeatureInitialize, tspl.New().InitialPrinter())
+}
+
+// SetTear enables or disables feeding labels to the tear position after printing
+func (p *Printer) SetTear(on bool) error {
+	return p.runCommand(tspl.FeatureTear, tspl.New().SetTear(on))
+}
+
+// SetSpeed sets the print speed in inches per second
+func (p *Printer) SetSpeed(ips float64) error {
+	model := p.Model()
+	valid := false
+	for _, s := range model.Speeds {
+		if s == ips {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("speed %g ips is not supported by the %s", ips, model.Name)
+	}
+	return p.runCommand(tspl.FeatureSpeed, tspl.New().Speed(ips))
+}
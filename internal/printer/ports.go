@@ -0,0 +1,46 @@
+package printer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PortInfo describes a serial port that a printer may be connected to
+type PortInfo struct {
+	Path          string // /dev/rfcomm0, /dev/ttyUSB0, COM3, ...
+	Driver        string // kernel driver (rfcomm, cp210x, cdc_acm) or Windows service (BthModem, usbser)
+	VID           string // USB vendor ID (hex), if USB
+	PID           string // USB product ID (hex), if USB
+	SerialNumber  string // USB serial number, if known
+	BluetoothAddr string // remote device address, if Bluetooth
+	Channel       int    // RFCOMM channel, if known
+	Description   string // human readable description
+}
+
+// IsUSB reports whether the port belongs to a USB device
+func (p PortInfo) IsUSB() bool {
+	return p.VID != "" && p.PID != ""
+}
+
+// IsBluetooth reports whether the port is a Bluetooth serial link
+func (p PortInfo) IsBluetooth() bool {
+	return p.BluetoothAddr != "" || p.Driver == "rfcomm" || strings.EqualFold(p.Driver, "BthModem")
+}
+
+// String returns a one-line label such as "/dev/ttyUSB0 - CP2102 USB to UART (10c4:ea60)"
+func (p PortInfo) String() string {
+	var details []string
+	if p.Description != "" {
+		details = append(details, p.Description)
+	}
+	if p.IsUSB() {
+		details = append(details, fmt.Sprintf("(%s:%s)", strings.ToLower(p.VID), strings.ToLower(p.PID)))
+	}
+	if p.BluetoothAddr != "" {
+		details = append(details, fmt.Sprintf("(%s)", p.BluetoothAddr))
+	}
+	if len(details) == 0 {
+		return p.Path
+	}
+	return p.Path + " - " + strings.Join(details, " ")
+}
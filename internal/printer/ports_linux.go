@@ -0,0 +1,117 @@
+//go:build linux
+
+package printer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// serialDevicePatterns are the /dev nodes that can be printers
+var serialDevicePatterns = []string{"/dev/rfcomm*", "/dev/ttyUSB*", "/dev/ttyACM*"}
+
+// ListPorts enumerates serial ports with driver, USB and Bluetooth metadata
+func ListPorts() ([]PortInfo, error) {
+	paths, err := ListSerialPorts()
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make([]PortInfo, 0, len(paths))
+	for _, path := range paths {
+		ports = append(ports, portInfo(path))
+	}
+	return ports, nil
+}
+
+// ListSerialPorts returns available serial ports (for manual connection)
+func ListSerialPorts() ([]string, error) {
+	var ports []string
+	for _, pattern := range serialDevicePatterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		ports = append(ports, matches...)
+	}
+	return ports, nil
+}
+
+// portInfo collects metadata for a /dev node from sysfs and rfcomm
+func portInfo(path string) PortInfo {
+	name := filepath.Base(path)
+	info := PortInfo{Path: path}
+
+	if strings.HasPrefix(name, "rfcomm") {
+		info.Driver = "rfcomm"
+		info.BluetoothAddr, info.Channel = rfcommBinding(path)
+		info.Description = "Bluetooth serial"
+		if info.Channel > 0 {
+			info.Description = fmt.Sprintf("Bluetooth serial, channel %d", info.Channel)
+		}
+		return info
+	}
+
+	devicePath, err := filepath.EvalSymlinks(filepath.Join("/sys/class/tty", name, "device"))
+	if err != nil {
+		return info
+	}
+	if driver, err := filepath.EvalSymlinks(filepath.Join(devicePath, "driver")); err == nil {
+		info.Driver = filepath.Base(driver)
+	}
+
+	// Walk up from the tty's interface to the USB device that owns it
+	for dir := devicePath; dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		vid := readSysfs(dir, "idVendor")
+		if vid == "" {
+			continue
+		}
+		info.VID = vid
+		info.PID = readSysfs(dir, "idProduct")
+		info.SerialNumber = readSysfs(dir, "serial")
+		info.Description = strings.TrimSpace(readSysfs(dir, "manufacturer") + " " + readSysfs(dir, "product"))
+		break
+	}
+
+	if info.Description == "" {
+		info.Description = info.Driver
+	}
+	return info
+}
+
+// readSysfs returns the trimmed contents of a sysfs attribute, or "" if missing
+func readSysfs(dir, attr string) string {
+	data, err := os.ReadFile(filepath.Join(dir, attr))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// rfcommBinding returns the remote address and channel an rfcomm device is bound to
+// Output of "rfcomm show rfcomm0" looks like:
+// "rfcomm0: 00:11:22:33:44:55 -> AA:BB:CC:DD:EE:FF channel 1 connected [tty-attached]"
+func rfcommBinding(path string) (string, int) {
+	out, err := exec.Command("rfcomm", "show", filepath.Base(path)).Output()
+	if err != nil {
+		return "", 0
+	}
+
+	fields := strings.Fields(string(out))
+	var addr string
+	var channel int
+	for i, f := range fields {
+		if f == "->" && i+1 < len(fields) {
+			addr = fields[i+1]
+		}
+		if f == "channel" && i+1 < len(fields) {
+			fmt.Sscanf(fields[i+1], "%d", &channel)
+		}
+	}
+	return addr, channel
+}
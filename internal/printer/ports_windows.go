@@ -0,0 +1,157 @@
+//go:build windows
+
+package printer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.bug.st/serial/enumerator"
+	"golang.org/x/sys/windows/registry"
+)
+
+// ListPorts enumerates COM ports with driver, USB and Bluetooth metadata
+func ListPorts() ([]PortInfo, error) {
+	drivers, err := serialCommDrivers()
+	if err != nil {
+		return nil, err
+	}
+	btAddrs := bluetoothPortAddresses()
+
+	// Product names and USB IDs come from SetupAPI; missing details are not fatal
+	details := make(map[string]*enumerator.PortDetails)
+	if list, err := enumerator.GetDetailedPortsList(); err == nil {
+		for _, d := range list {
+			details[strings.ToUpper(d.Name)] = d
+		}
+	}
+
+	ports := make([]PortInfo, 0, len(drivers))
+	for port, driver := range drivers {
+		info := PortInfo{
+			Path:          port,
+			Driver:        driver,
+			BluetoothAddr: btAddrs[strings.ToUpper(port)],
+		}
+		if d, ok := details[strings.ToUpper(port)]; ok {
+			info.Description = d.Product
+			if d.IsUSB {
+				info.VID = d.VID
+				info.PID = d.PID
+				info.SerialNumber = d.SerialNumber
+			}
+		}
+		if info.Description == "" {
+			info.Description = driver
+		}
+		ports = append(ports, info)
+	}
+
+	sort.Slice(ports, func(i, j int) bool {
+		return comPortNumber(ports[i].Path) < comPortNumber(ports[j].Path)
+	})
+	return ports, nil
+}
+
+// serialCommDrivers maps COM port names to the driver that created them, e.g.
+// "COM5" -> "BthModem" for the "\Device\BthModem0" entry in SERIALCOMM
+func serialCommDrivers() (map[string]string, error) {
+	ports := make(map[string]string)
+
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `HARDWARE\DEVICEMAP\SERIALCOMM`, registry.READ)
+	if err == registry.ErrNotExist {
+		// The key only exists while at least one COM port is present
+		return ports, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+
+	names, err := key.ReadValueNames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		val, _, err := key.GetStringValue(name)
+		if err != nil {
+			continue
+		}
+		driver := strings.TrimPrefix(name, `\Device\`)
+		driver = strings.TrimRight(driver, "0123456789")
+		ports[val] = driver
+	}
+
+	return ports, nil
+}
+
+// bluetoothPortAddresses maps COM port names to the remote Bluetooth address
+// Outgoing SPP ports are listed under Enum\BTHENUM with instance IDs such as
+// "7&1a2b3c4d&0&001122334455_C00000000" where 001122334455 is the address
+func bluetoothPortAddresses() map[string]string {
+	addrs := make(map[string]string)
+
+	root, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Enum\BTHENUM`, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return addrs
+	}
+	defer root.Close()
+
+	services, err := root.ReadSubKeyNames(-1)
+	if err != nil {
+		return addrs
+	}
+
+	for _, service := range services {
+		svcKey, err := registry.OpenKey(root, service, registry.ENUMERATE_SUB_KEYS)
+		if err != nil {
+			continue
+		}
+		instances, _ := svcKey.ReadSubKeyNames(-1)
+		for _, instance := range instances {
+			params, err := registry.OpenKey(svcKey, instance+`\Device Parameters`, registry.QUERY_VALUE)
+			if err != nil {
+				continue
+			}
+			port, _, err := params.GetStringValue("PortName")
+			params.Close()
+			if err != nil {
+				continue
+			}
+			if addr := parseBTHENUMAddress(instance); addr != "" {
+				addrs[strings.ToUpper(port)] = addr
+			}
+		}
+		svcKey.Close()
+	}
+
+	return addrs
+}
+
+// parseBTHENUMAddress extracts "00:11:22:33:44:55" from a BTHENUM instance ID
+// Incoming ports have an all-zero address and are ignored
+func parseBTHENUMAddress(instance string) string {
+	parts := strings.Split(instance, "&")
+	last := parts[len(parts)-1]
+	if i := strings.Index(last, "_"); i >= 0 {
+		last = last[:i]
+	}
+	if len(last) != 12 || strings.Trim(last, "0") == "" {
+		return ""
+	}
+
+	var octets []string
+	for i := 0; i < 12; i += 2 {
+		octets = append(octets, strings.ToUpper(last[i:i+2]))
+	}
+	return strings.Join(octets, ":")
+}
+
+// comPortNumber returns N for "COMN" so ports sort numerically
+func comPortNumber(port string) int {
+	var n int
+	fmt.Sscanf(strings.ToUpper(port), "COM%d", &n)
+	return n
+}
@@ -5,33 +5,57 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"go.bug.st/serial"
+
+	"nelko-print/internal/tspl"
 )
 
 var (
-	ErrNotConnected = errors.New("printer not connected")
-	ErrTimeout      = errors.New("operation timed out")
+	ErrNotConnected     = errors.New("printer not connected")
+	ErrTimeout          = errors.New("operation timed out")
+	ErrModelUnsupported = errors.New("not supported by this printer model")
 )
 
-// Printer represents a connection to the Nelko P21
+// Printer represents a connection to a TSPL label printer (the Nelko P21 by default)
 type Printer struct {
 	port     serial.Port
 	portName string
 	mac      string
+
+	// The model is set from the UI while jobs print in the background
+	mu    sync.Mutex
+	model tspl.Model
+	rle   rleSupport // whether the printer accepts compressed bitmaps
 }
 
+// rleSupport is what a printer's answer to tspl.RLEProbe showed
+type rleSupport int
+
+const (
+	rleUnknown  rleSupport = iota // not probed, or the status was unclear
+	rleAccepted                   // no error after the probe
+	rleRejected                   // only the error bit after the probe
+)
+
+// statusError is the status byte bit for an error other than the media,
+// head or ribbon, such as a command the printer cannot parse
+const statusError = 0x80
+
+// rleCheckDelay is how long the printer gets to parse the RLE probe before
+// its status is checked
+var rleCheckDelay = 300 * time.Millisecond
+
 // FindRFCOMMDevices lists available /dev/rfcomm* devices
 func FindRFCOMMDevices() ([]string, error) {
-	// Check for existing rfcomm devices
-	out, err := exec.Command("ls", "/dev/rfcomm*").Output()
+	devices, err := filepath.Glob("/dev/rfcomm*")
 	if err != nil {
-		return nil, nil // No devices found, not an error
+		return nil, err
 	}
-
-	devices := strings.Fields(string(out))
 	return devices, nil
 }
 
@@ -105,6 +129,7 @@ func Connect(portName string) (*Printer, error) {
 	p := &Printer{
 		port:     port,
 		portName: portName,
+		model:    tspl.ModelP21,
 	}
 
 	return p, nil
@@ -140,17 +165,43 @@ func (p *Printer) sendCommand(cmd string) (string, error) {
 	return strings.TrimSpace(response), nil
 }
 
+// SetModel selects the printer model, which decides which queries are sent
+func (p *Printer) SetModel(m tspl.Model) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.model = m
+}
+
+// Model returns the printer model
+func (p *Printer) Model() tspl.Model {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.model
+}
+
+// unsupported is the error for an operation the printer model lacks; it
+// matches ErrModelUnsupported
+func (p *Printer) unsupported(op string) error {
+	return fmt.Errorf("%s: %s %w", op, p.Model().Name, ErrModelUnsupported)
+}
+
 // GetBattery queries the battery level
 func (p *Printer) GetBattery() (int, error) {
-	resp, err := p.sendCommand("BATTERY?")
+	model := p.Model()
+	if !model.Supports(tspl.FeatureBattery) || model.BatteryQuery == "" {
+		return 0, p.unsupported("battery level")
+	}
+
+	resp, err := p.sendCommand(model.BatteryQuery)
 	if err != nil {
 		return 0, err
 	}
 
-	// Response format: "BATTERY" followed by bytes
-	if len(resp) > 7 {
-		// First byte after "BATTERY" is percentage
-		return int(resp[7]), nil
+	// Response format: the query name (e.g. "BATTERY") followed by bytes
+	prefix := strings.TrimSuffix(model.BatteryQuery, "?")
+	if len(resp) > len(prefix) {
+		// First byte after the prefix is percentage
+		return int(resp[len(prefix)]), nil
 	}
 
 	return 0, errors.New("invalid battery response")
@@ -158,6 +209,9 @@ func (p *Printer) GetBattery() (int, error) {
 
 // GetConfig queries printer configuration
 func (p *Printer) GetConfig() (string, error) {
+	if !p.Model().Supports(tspl.FeatureConfig) {
+		return "", p.unsupported("configuration query")
+	}
 	return p.sendCommand("CONFIG?")
 }
 
@@ -167,27 +221,50 @@ func (p *Printer) CancelPause() error {
 	return err
 }
 
-// CheckReady checks if printer is ready
+// CheckReady checks if printer is ready using the model's status protocol
 func (p *Printer) CheckReady() (bool, error) {
-	_, err := p.port.Write([]byte("\x1b!?"))
-	if err != nil {
-		return false, err
+	status, err := p.status()
+	return err == nil && status == 0, err
+}
+
+// status reads the printer's status byte
+// With StatusESC the printer answers "ESC ! ?" with one byte, 0 when it is
+// ready and otherwise bits for head open, paper jam, out of paper, out of
+// ribbon, pause, printing and other errors
+func (p *Printer) status() (byte, error) {
+	if p.port == nil {
+		return 0, ErrNotConnected
+	}
+	if p.Model().Status != tspl.StatusESC {
+		return 0, p.unsupported("status query")
+	}
+
+	if _, err := p.port.Write([]byte("\x1b!?")); err != nil {
+		return 0, err
 	}
-	// Read response
 	buf := make([]byte, 32)
-	_, err = p.port.Read(buf)
-	return err == nil, err
+	n, err := p.port.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, ErrTimeout
+	}
+	return buf[0], nil
 }
 
 // Print sends raw print data to the printer
+// Send a whole batch as one tspl.Job so the pause check runs once per batch
 func (p *Printer) Print(data []byte) error {
 	if p.port == nil {
 		return ErrNotConnected
 	}
 
 	// Cancel any pause state first
-	p.CancelPause()
-	time.Sleep(100 * time.Millisecond)
+	if p.Model().Supports(tspl.FeatureCancelPause) {
+		p.CancelPause()
+		time.Sleep(100 * time.Millisecond)
+	}
 
 	// Send print data
 	_, err := p.port.Write(data)
@@ -198,6 +275,67 @@ func (p *Printer) Print(data []byte) error {
 	return nil
 }
 
+// PrintJob sends a print job
+// Before the first job with run-length encoded bitmaps on a model not known
+// to accept them, the printer is sent tspl.RLEProbe: if its status then shows
+// just the error bit, it does not accept BITMAP mode 3 and its jobs are sent
+// with plain bitmaps. The probe prints nothing, so no label is printed twice;
+// if the status is unclear, e.g. out of paper, the job is sent with plain
+// bitmaps and the printer is probed again for the next one
+func (p *Printer) PrintJob(job *tspl.Job) error {
+	if job.Compressed() && !p.acceptsRLE() {
+		job = job.Uncompressed()
+	}
+	return p.Print(job.Bytes())
+}
+
+// acceptsRLE reports whether compressed bitmaps can be sent, probing the
+// printer if that is not known yet
+func (p *Printer) acceptsRLE() bool {
+	p.mu.Lock()
+	model, rle := p.model, p.rle
+	p.mu.Unlock()
+
+	switch {
+	case model.Supports(tspl.FeatureBitmapRLE) || rle == rleAccepted:
+		return true
+	case rle == rleRejected:
+		return false
+	case model.Status != tspl.StatusESC:
+		// Without status there is no way to tell, so trust the setting
+		return true
+	}
+
+	rle = p.probeRLE()
+	p.mu.Lock()
+	p.rle = rle
+	p.mu.Unlock()
+	return rle == rleAccepted
+}
+
+// probeRLE sends tspl.RLEProbe and reads the printer's status before and after
+// The answer only counts if the printer was ready before the probe
+func (p *Printer) probeRLE() rleSupport {
+	if status, err := p.status(); err != nil || status != 0 {
+		return rleUnknown
+	}
+	if _, err := p.port.Write(tspl.RLEProbe()); err != nil {
+		return rleUnknown
+	}
+
+	time.Sleep(rleCheckDelay)
+	status, err := p.status()
+	switch {
+	case err != nil:
+		return rleUnknown
+	case status == 0:
+		return rleAccepted
+	case status == statusError:
+		return rleRejected
+	}
+	return rleUnknown
+}
+
 // PortName returns the current port name
 func (p *Printer) PortName() string {
 	return p.portName
@@ -0,0 +1,124 @@
+package printer
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"go.bug.st/serial"
+
+	"nelko-print/internal/imaging"
+	"nelko-print/internal/tspl"
+)
+
+// fakePort answers status queries from a list and records everything else
+type fakePort struct {
+	serial.Port // not called by the tests
+
+	statuses []byte // answers to the next status queries
+	queries  int
+	pending  []byte
+	written  bytes.Buffer
+}
+
+func (f *fakePort) Write(p []byte) (int, error) {
+	if string(p) == "\x1b!?" {
+		f.queries++
+		if len(f.statuses) > 0 {
+			f.pending, f.statuses = f.statuses[:1], f.statuses[1:]
+		}
+		return len(p), nil
+	}
+	return f.written.Write(p)
+}
+
+func (f *fakePort) Read(p []byte) (int, error) {
+	n := copy(p, f.pending)
+	f.pending = nil
+	return n, nil
+}
+
+// compressedJob is a one-label job that asks for run-length encoded bitmaps
+func compressedJob() *tspl.Job {
+	size := tspl.Label14x40
+	bm := imaging.NewBitmap(image.Rect(0, 0, size.PixelW, size.PixelH))
+	for y := 10; y < 200; y++ {
+		for x := 8; x < 40; x++ {
+			bm.SetBlack(x, y, true)
+		}
+	}
+	settings := tspl.JobSettings{Model: tspl.ModelP21, Size: size, Density: 8, Compress: true}
+	return tspl.NewJob(settings).AddLabel(bm, 1)
+}
+
+func TestPrintJobRLEFallback(t *testing.T) {
+	rleCheckDelay = 0
+	probe := tspl.RLEProbe()
+	compressed := compressedJob().Bytes()
+	plain := compressedJob().Uncompressed().Bytes()
+	if bytes.Equal(compressed, plain) {
+		t.Fatal("the test label does not compress")
+	}
+
+	tests := []struct {
+		name     string
+		statuses []byte // before and after the probe
+		probed   bool   // the probe was sent
+		want     []byte // the job sent
+		cached   rleSupport
+	}{
+		{"accepted", []byte{0, 0}, true, compressed, rleAccepted},
+		{"rejected", []byte{0, statusError}, true, plain, rleRejected},
+		{"not ready", []byte{0x04}, false, plain, rleUnknown},
+		{"other error", []byte{0, statusError | 0x04}, true, plain, rleUnknown},
+	}
+	for _, tt := range tests {
+		port := &fakePort{statuses: tt.statuses}
+		p := &Printer{port: port, model: tspl.ModelP21}
+		if err := p.PrintJob(compressedJob()); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		sent := port.written.Bytes()
+		if got := bytes.HasPrefix(sent, probe); got != tt.probed {
+			t.Errorf("%s: probe sent %v, want %v", tt.name, got, tt.probed)
+		}
+		if !bytes.HasSuffix(sent, tt.want) {
+			t.Errorf("%s: sent the wrong job", tt.name)
+		}
+		if n := bytes.Count(sent, []byte("PRINT ")); n != 1 {
+			t.Errorf("%s: %d PRINT commands, want 1", tt.name, n)
+		}
+		if p.rle != tt.cached {
+			t.Errorf("%s: RLE support %d, want %d", tt.name, p.rle, tt.cached)
+		}
+	}
+}
+
+func TestPrintJobRLEProbedOnce(t *testing.T) {
+	rleCheckDelay = 0
+	port := &fakePort{statuses: []byte{0, statusError}}
+	p := &Printer{port: port, model: tspl.ModelP21}
+	for i := 0; i < 3; i++ {
+		if err := p.PrintJob(compressedJob()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if port.queries != 2 {
+		t.Errorf("%d status queries, want 2 for one probe", port.queries)
+	}
+	if n := bytes.Count(port.written.Bytes(), []byte(",3,")); n != 1 {
+		t.Errorf("%d compressed bitmaps sent, want only the probe", n)
+	}
+
+	// A model that accepts compressed bitmaps is not probed
+	port = &fakePort{}
+	p = &Printer{port: port, model: tspl.ModelP21}
+	p.model.Features |= tspl.FeatureBitmapRLE
+	if err := p.PrintJob(compressedJob()); err != nil {
+		t.Fatal(err)
+	}
+	if port.queries != 0 || !bytes.HasSuffix(port.written.Bytes(), compressedJob().Bytes()) {
+		t.Errorf("probed %d times or sent a plain job to a model that accepts RLE", port.queries)
+	}
+}
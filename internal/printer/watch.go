@@ -0,0 +1,166 @@
+package printer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeviceEventType describes what happened to a serial device
+type DeviceEventType int
+
+const (
+	DeviceAdded DeviceEventType = iota
+	DeviceRemoved
+)
+
+func (t DeviceEventType) String() string {
+	if t == DeviceRemoved {
+		return "removed"
+	}
+	return "added"
+}
+
+// DeviceEvent is emitted when a serial or RFCOMM port appears or disappears
+type DeviceEvent struct {
+	Type DeviceEventType
+	Path string // e.g. /dev/rfcomm0 on Linux, COM3 on Windows
+}
+
+// DeviceWatcher reports serial port hotplug events
+// Use NewDeviceWatcher for the platform implementation, or NewPollingWatcher
+// with a custom list function to drive it from tests
+type DeviceWatcher interface {
+	// Events returns the channel events are delivered on
+	// It is closed when the watcher is closed
+	Events() <-chan DeviceEvent
+	// Close stops the watcher
+	Close() error
+}
+
+// DefaultPollInterval is how often a PollingWatcher rescans ports
+const DefaultPollInterval = 2 * time.Second
+
+// PollingWatcher detects port changes by periodically comparing port lists
+type PollingWatcher struct {
+	list     func() ([]string, error)
+	interval time.Duration
+	trigger  chan struct{}
+	events   chan DeviceEvent
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewPollingWatcher starts a watcher that calls list every interval and emits
+// an event for each port that was added or removed since the previous call
+func NewPollingWatcher(list func() ([]string, error), interval time.Duration) *PollingWatcher {
+	w := &PollingWatcher{
+		list:     list,
+		interval: interval,
+		trigger:  make(chan struct{}, 1),
+		events:   make(chan DeviceEvent, 16),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Events returns the channel events are delivered on
+func (w *PollingWatcher) Events() <-chan DeviceEvent {
+	return w.events
+}
+
+// Rescan asks the watcher to compare port lists now instead of waiting for the next tick
+func (w *PollingWatcher) Rescan() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the watcher
+func (w *PollingWatcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	return nil
+}
+
+func (w *PollingWatcher) run() {
+	defer close(w.events)
+
+	known := w.snapshot()
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-tick:
+		case <-w.trigger:
+		}
+
+		current := w.snapshot()
+		if current == nil {
+			// Keep the last good list so a failed scan doesn't lose events
+			continue
+		}
+		for _, ev := range diffPorts(known, current) {
+			select {
+			case w.events <- ev:
+			case <-w.done:
+				return
+			}
+		}
+		known = current
+	}
+}
+
+func (w *PollingWatcher) snapshot() map[string]bool {
+	ports, err := w.list()
+	if err != nil {
+		return nil
+	}
+	set := make(map[string]bool, len(ports))
+	for _, p := range ports {
+		set[p] = true
+	}
+	return set
+}
+
+// diffPorts returns removals followed by additions, each sorted by path
+func diffPorts(old, current map[string]bool) []DeviceEvent {
+	if old == nil || current == nil {
+		// A failed scan says nothing about which ports changed
+		return nil
+	}
+
+	var removed, added []string
+	for p := range old {
+		if !current[p] {
+			removed = append(removed, p)
+		}
+	}
+	for p := range current {
+		if !old[p] {
+			added = append(added, p)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	events := make([]DeviceEvent, 0, len(removed)+len(added))
+	for _, p := range removed {
+		events = append(events, DeviceEvent{Type: DeviceRemoved, Path: p})
+	}
+	for _, p := range added {
+		events = append(events, DeviceEvent{Type: DeviceAdded, Path: p})
+	}
+	return events
+}
@@ -0,0 +1,144 @@
+//go:build linux
+
+package printer
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// watchedTTYPrefixes are the tty device names that can be printers
+var watchedTTYPrefixes = []string{"rfcomm", "ttyUSB", "ttyACM"}
+
+// ueventWatcher listens for kernel uevents on a netlink socket
+type ueventWatcher struct {
+	sock   *os.File
+	events chan DeviceEvent
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewDeviceWatcher watches for serial and RFCOMM devices being added or removed
+// It listens to kernel uevents and falls back to polling if netlink is unavailable
+func NewDeviceWatcher() (DeviceWatcher, error) {
+	w, err := newUeventWatcher()
+	if err != nil {
+		return NewPollingWatcher(ListSerialPorts, DefaultPollInterval), nil
+	}
+	return w, nil
+}
+
+func newUeventWatcher() (*ueventWatcher, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group 1 receives the kernel's broadcast uevents (no privileges needed)
+	addr := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: 1,
+	}
+	if err := syscall.Bind(fd, addr); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	// Non-blocking so the runtime poller can interrupt reads on Close
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	w := &ueventWatcher{
+		sock:   os.NewFile(uintptr(fd), "uevent"),
+		events: make(chan DeviceEvent, 16),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Events returns the channel events are delivered on
+func (w *ueventWatcher) Events() <-chan DeviceEvent {
+	return w.events
+}
+
+// Close stops the watcher
+func (w *ueventWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.sock.Close()
+	})
+	return err
+}
+
+func (w *ueventWatcher) run() {
+	defer close(w.events)
+
+	buf := make([]byte, 8192)
+	for {
+		n, err := w.sock.Read(buf)
+		if err != nil {
+			return
+		}
+
+		ev, ok := parseUevent(buf[:n])
+		if !ok {
+			continue
+		}
+
+		select {
+		case w.events <- ev:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// parseUevent extracts a tty add/remove event from a kernel uevent message
+// Messages look like "add@/devices/...\x00ACTION=add\x00SUBSYSTEM=tty\x00DEVNAME=rfcomm0\x00..."
+func parseUevent(msg []byte) (DeviceEvent, bool) {
+	fields := bytes.Split(msg, []byte{0})
+	if len(fields) < 2 {
+		return DeviceEvent{}, false
+	}
+
+	vars := make(map[string]string)
+	for _, f := range fields[1:] {
+		if k, v, ok := strings.Cut(string(f), "="); ok {
+			vars[k] = v
+		}
+	}
+
+	if vars["SUBSYSTEM"] != "tty" {
+		return DeviceEvent{}, false
+	}
+
+	name := strings.TrimPrefix(vars["DEVNAME"], "/dev/")
+	watched := false
+	for _, prefix := range watchedTTYPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			watched = true
+			break
+		}
+	}
+	if !watched {
+		return DeviceEvent{}, false
+	}
+
+	ev := DeviceEvent{Path: "/dev/" + name}
+	switch vars["ACTION"] {
+	case "add":
+		ev.Type = DeviceAdded
+	case "remove":
+		ev.Type = DeviceRemoved
+	default:
+		return DeviceEvent{}, false
+	}
+	return ev, true
+}
@@ -0,0 +1,84 @@
+//go:build linux
+
+package printer
+
+import (
+	"strings"
+	"testing"
+)
+
+// uevent builds a kernel uevent message from its header and variables
+func uevent(header string, vars ...string) []byte {
+	return []byte(header + "\x00" + strings.Join(vars, "\x00") + "\x00")
+}
+
+func TestParseUevent(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  []byte
+		want DeviceEvent
+		ok   bool
+	}{
+		{
+			name: "rfcomm bind",
+			msg: uevent("add@/devices/virtual/tty/rfcomm0",
+				"ACTION=add", "DEVPATH=/devices/virtual/tty/rfcomm0", "SUBSYSTEM=tty",
+				"MAJOR=216", "MINOR=0", "DEVNAME=rfcomm0", "SEQNUM=4211"),
+			want: DeviceEvent{Type: DeviceAdded, Path: "/dev/rfcomm0"},
+			ok:   true,
+		},
+		{
+			name: "rfcomm release",
+			msg: uevent("remove@/devices/virtual/tty/rfcomm0",
+				"ACTION=remove", "DEVPATH=/devices/virtual/tty/rfcomm0", "SUBSYSTEM=tty",
+				"MAJOR=216", "MINOR=0", "DEVNAME=rfcomm0", "SEQNUM=4236"),
+			want: DeviceEvent{Type: DeviceRemoved, Path: "/dev/rfcomm0"},
+			ok:   true,
+		},
+		{
+			name: "usb serial adapter",
+			msg: uevent("add@/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/ttyUSB0/tty/ttyUSB0",
+				"ACTION=add", "DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/ttyUSB0/tty/ttyUSB0",
+				"SUBSYSTEM=tty", "MAJOR=188", "MINOR=0", "DEVNAME=ttyUSB0", "SEQNUM=5120"),
+			want: DeviceEvent{Type: DeviceAdded, Path: "/dev/ttyUSB0"},
+			ok:   true,
+		},
+		{
+			name: "cdc acm with full device name",
+			msg: uevent("remove@/devices/pci0000:00/0000:00:14.0/usb1/1-3/1-3:1.0/tty/ttyACM0",
+				"ACTION=remove", "SUBSYSTEM=tty", "DEVNAME=/dev/ttyACM0", "SEQNUM=5188"),
+			want: DeviceEvent{Type: DeviceRemoved, Path: "/dev/ttyACM0"},
+			ok:   true,
+		},
+		{
+			name: "other tty",
+			msg: uevent("add@/devices/virtual/tty/tty63",
+				"ACTION=add", "SUBSYSTEM=tty", "DEVNAME=tty63", "SEQNUM=300"),
+		},
+		{
+			name: "usb interface",
+			msg: uevent("add@/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0",
+				"ACTION=add", "SUBSYSTEM=usb", "DEVTYPE=usb_interface", "SEQNUM=5118"),
+		},
+		{
+			name: "change event",
+			msg: uevent("change@/devices/virtual/tty/rfcomm0",
+				"ACTION=change", "SUBSYSTEM=tty", "DEVNAME=rfcomm0", "SEQNUM=4220"),
+		},
+		{
+			name: "libudev message",
+			msg:  []byte("libudev\x00\xfe\xed\xca\xfe"),
+		},
+		{
+			name: "empty",
+			msg:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseUevent(tt.msg)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: got %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package printer
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePorts is a port list the tests change between scans
+type fakePorts struct {
+	mu    sync.Mutex
+	ports []string
+	err   error
+}
+
+func (f *fakePorts) set(err error, ports ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ports, f.err = ports, err
+}
+
+func (f *fakePorts) list() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.ports...), f.err
+}
+
+// rescan triggers a scan and collects the events it produces
+func rescan(t *testing.T, w *PollingWatcher) []DeviceEvent {
+	t.Helper()
+	w.Rescan()
+
+	var events []DeviceEvent
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case ev := <-w.Events():
+			events = append(events, ev)
+		case <-timeout:
+			return events
+		}
+	}
+}
+
+func TestPollingWatcher(t *testing.T) {
+	ports := &fakePorts{}
+	ports.set(nil, "/dev/ttyUSB0")
+	w := NewPollingWatcher(ports.list, 0)
+	defer w.Close()
+
+	steps := []struct {
+		name  string
+		err   error
+		ports []string
+		want  []DeviceEvent
+	}{
+		{"no change", nil, []string{"/dev/ttyUSB0"}, nil},
+		{"add", nil, []string{"/dev/ttyUSB0", "/dev/rfcomm0"}, []DeviceEvent{
+			{Type: DeviceAdded, Path: "/dev/rfcomm0"},
+		}},
+		{"remove", nil, []string{"/dev/rfcomm0"}, []DeviceEvent{
+			{Type: DeviceRemoved, Path: "/dev/ttyUSB0"},
+		}},
+		{"failed scan", errors.New("busy"), nil, nil},
+		{"add and remove", nil, []string{"/dev/ttyACM0", "/dev/ttyUSB1"}, []DeviceEvent{
+			{Type: DeviceRemoved, Path: "/dev/rfcomm0"},
+			{Type: DeviceAdded, Path: "/dev/ttyACM0"},
+			{Type: DeviceAdded, Path: "/dev/ttyUSB1"},
+		}},
+	}
+	for _, step := range steps {
+		ports.set(step.err, step.ports...)
+		if got := rescan(t, w); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: got %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestPollingWatcherClose(t *testing.T) {
+	w := NewPollingWatcher(func() ([]string, error) { return nil, nil }, time.Millisecond)
+	w.Close()
+	w.Close()
+
+	select {
+	case _, ok := <-w.Events():
+		if ok {
+			t.Error("got an event after Close")
+		}
+	case <-time.After(time.Second):
+		t.Error("Events was not closed")
+	}
+}
@@ -0,0 +1,77 @@
+//go:build windows
+
+package printer
+
+import (
+	"sync"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// registryWatcher rescans COM ports whenever the SERIALCOMM registry key changes
+type registryWatcher struct {
+	*PollingWatcher
+	key  registry.Key
+	stop windows.Handle
+	once sync.Once
+}
+
+// NewDeviceWatcher watches for COM ports being added or removed
+// It waits for changes to HARDWARE\DEVICEMAP\SERIALCOMM and falls back to
+// polling if the key cannot be watched
+func NewDeviceWatcher() (DeviceWatcher, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `HARDWARE\DEVICEMAP\SERIALCOMM`, registry.NOTIFY|registry.READ)
+	if err != nil {
+		return NewPollingWatcher(ListSerialPorts, DefaultPollInterval), nil
+	}
+
+	stop, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		key.Close()
+		return NewPollingWatcher(ListSerialPorts, DefaultPollInterval), nil
+	}
+
+	w := &registryWatcher{
+		// Scans are triggered by registry notifications; the slow poll is a
+		// safety net in case notifications stop arriving
+		PollingWatcher: NewPollingWatcher(ListSerialPorts, 5*DefaultPollInterval),
+		key:            key,
+		stop:           stop,
+	}
+	go w.notifyLoop()
+	return w, nil
+}
+
+// Close stops the watcher
+func (w *registryWatcher) Close() error {
+	w.once.Do(func() {
+		windows.SetEvent(w.stop)
+		w.PollingWatcher.Close()
+	})
+	return nil
+}
+
+func (w *registryWatcher) notifyLoop() {
+	defer w.key.Close()
+	defer windows.CloseHandle(w.stop)
+
+	changed, err := windows.CreateEvent(nil, 0, 0, nil)
+	if err != nil {
+		return
+	}
+	defer windows.CloseHandle(changed)
+
+	filter := uint32(windows.REG_NOTIFY_CHANGE_NAME | windows.REG_NOTIFY_CHANGE_LAST_SET)
+	for {
+		if err := windows.RegNotifyChangeKeyValue(windows.Handle(w.key), false, filter, changed, true); err != nil {
+			return
+		}
+
+		idx, err := windows.WaitForMultipleObjects([]windows.Handle{changed, w.stop}, false, windows.INFINITE)
+		if err != nil || idx != windows.WAIT_OBJECT_0 {
+			return
+		}
+		w.Rescan()
+	}
+}
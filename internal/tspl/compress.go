@@ -0,0 +1,55 @@
+package tspl
+
+// bitmapModeRLE is the BITMAP mode for run-length encoded data
+const bitmapModeRLE = 3
+
+// packBits run-length encodes data the PackBits way: a header byte n in
+// 0..127 is followed by n+1 literal bytes, n in 129..255 repeats the next
+// byte 257-n times
+// Labels are mostly blank, so runs of 0xFF compress to two bytes per 128
+func packBits(data []byte) []byte {
+	out := make([]byte, 0, len(data)/4)
+	for i := 0; i < len(data); {
+		// Count the run starting at i
+		run := 1
+		for i+run < len(data) && run < 128 && data[i+run] == data[i] {
+			run++
+		}
+		if run > 1 {
+			out = append(out, byte(257-run), data[i])
+			i += run
+			continue
+		}
+
+		// Collect literals up to the next run of at least two bytes
+		start := i
+		for i < len(data) && i-start < 128 {
+			if i+1 < len(data) && data[i+1] == data[i] {
+				break
+			}
+			i++
+		}
+		out = append(out, byte(i-start-1))
+		out = append(out, data[start:i]...)
+	}
+	return out
+}
+
+// writeBitmap adds a label bitmap run-length encoded if compress is set and
+// that makes it smaller, otherwise as plain data
+func writeBitmap(cmd *Command, compress bool, x, y, widthBytes, height int, data []byte) {
+	if compress {
+		if packed := packBits(data); len(packed) < len(data) {
+			cmd.BitmapMode(x, y, widthBytes, height, bitmapModeRLE, packed)
+			return
+		}
+	}
+	cmd.Bitmap(x, y, widthBytes, height, data)
+}
+
+// RLEProbe returns one run-length encoded blank byte drawn into the image
+// buffer, without a PRINT; a printer that does not accept BITMAP mode 3
+// reports an error for it, and nothing is printed either way
+func RLEProbe() []byte {
+	return New().BitmapMode(0, 0, 1, 1, bitmapModeRLE, packBits([]byte{blankByte})).Bytes()
+}
@@ -0,0 +1,117 @@
+package tspl
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// unpackBits decodes PackBits data
+func unpackBits(t *testing.T, packed []byte) []byte {
+	t.Helper()
+	var out []byte
+	for i := 0; i < len(packed); {
+		n := int(packed[i])
+		i++
+		switch {
+		case n < 128:
+			if i+n+1 > len(packed) {
+				t.Fatalf("literal of %d bytes at %d runs past the end", n+1, i-1)
+			}
+			out = append(out, packed[i:i+n+1]...)
+			i += n + 1
+		case n > 128:
+			if i >= len(packed) {
+				t.Fatalf("run at %d has no byte", i-1)
+			}
+			out = append(out, bytes.Repeat(packed[i:i+1], 257-n)...)
+			i++
+		default:
+			t.Fatalf("no-op header 128 at %d", i-1)
+		}
+	}
+	return out
+}
+
+func TestPackBitsRoundTrip(t *testing.T) {
+	literals := make([]byte, 300)
+	for i := range literals {
+		literals[i] = byte(i)
+	}
+	blankRows := bytes.Repeat([]byte{blankByte}, 12*40)
+	mixed := append(append(bytes.Repeat([]byte{blankByte}, 130), 0x00, 0x81, 0x7E, 0x7E), bytes.Repeat([]byte{0x00}, 129)...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int // largest acceptable encoding, 0 for no limit
+	}{
+		{"empty", nil, 0},
+		{"one byte", []byte{0x42}, 2},
+		{"two equal bytes", []byte{0x42, 0x42}, 2},
+		{"run of 128", bytes.Repeat([]byte{0xAA}, 128), 2},
+		{"run longer than 128", bytes.Repeat([]byte{0xAA}, 300), 6},
+		{"literals only", literals, 300 + 3},
+		{"blank rows", blankRows, 2 * (len(blankRows)/128 + 1)},
+		{"mixed", mixed, 0},
+	}
+	for _, tt := range tests {
+		packed := packBits(tt.data)
+		if got := unpackBits(t, packed); !bytes.Equal(got, tt.data) {
+			t.Errorf("%s: round trip gave %d bytes, want %d", tt.name, len(got), len(tt.data))
+		}
+		if tt.maxSize > 0 && len(packed) > tt.maxSize {
+			t.Errorf("%s: packed to %d bytes, want at most %d", tt.name, len(packed), tt.maxSize)
+		}
+	}
+}
+
+func TestWriteBitmap(t *testing.T) {
+	blank := bytes.Repeat([]byte{blankByte}, 12*20)
+	noisy := make([]byte, 12*20)
+	for i := range noisy {
+		noisy[i] = byte(i*7 + i/3)
+	}
+
+	tests := []struct {
+		name     string
+		compress bool
+		data     []byte
+		mode     int
+	}{
+		{"plain", false, blank, 1},
+		{"compressed", true, blank, bitmapModeRLE},
+		{"compression does not help", true, noisy, 1},
+	}
+	for _, tt := range tests {
+		cmd := New()
+		writeBitmap(cmd, tt.compress, 8, 4, 12, 20, tt.data)
+		header := fmt.Sprintf("BITMAP 8,4,12,20,%d,", tt.mode)
+		if !strings.HasPrefix(cmd.String(), header) {
+			t.Errorf("%s: got %.30q, want it to start with %q", tt.name, cmd.String(), header)
+		}
+	}
+}
+
+// rleBitmap matches the header of a BITMAP mode 3 command
+var rleBitmap = regexp.MustCompile(`BITMAP \d+,\d+,\d+,\d+,3,`)
+
+func TestJobUncompressed(t *testing.T) {
+	size := Label14x40
+	bitmap := labelImage(size, [4]int{0, 100, size.PixelW, 130})
+
+	job := NewJob(JobSettings{Model: ModelP21, Size: size, Density: 8, Compress: true}).AddLabel(bitmap, 1)
+	if !job.Compressed() || !rleBitmap.Match(job.Bytes()) {
+		t.Fatal("compressed job does not use BITMAP mode 3")
+	}
+
+	plain := job.Uncompressed()
+	if plain.Compressed() || rleBitmap.Match(plain.Bytes()) {
+		t.Error("uncompressed job uses BITMAP mode 3")
+	}
+	if !job.Compressed() {
+		t.Error("Uncompressed changed the original job")
+	}
+}
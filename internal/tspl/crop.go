@@ -0,0 +1,89 @@
+package tspl
+
+// blankByte is a bitmap byte with no black dots (sent data uses 1 for white)
+const blankByte = 0xFF
+
+// bitmapHeaderCost approximates the bytes a BITMAP command adds besides its
+// data; blank gaps smaller than this are cheaper to send than to split on
+const bitmapHeaderCost = 24
+
+// bitmapRegion is a rectangle of a label bitmap in whole bytes across and rows down
+type bitmapRegion struct {
+	x, y          int // byte column and row of the top-left corner
+	width, height int // bytes per row and rows
+}
+
+// blankRow reports whether a row of the bitmap has no black dots
+func blankRow(data []byte, widthBytes, row int) bool {
+	for _, b := range data[row*widthBytes : (row+1)*widthBytes] {
+		if b != blankByte {
+			return false
+		}
+	}
+	return true
+}
+
+// bitmapRegions finds the parts of a label bitmap that contain black dots
+// Bands of rows separated by a wide enough blank gap become separate regions,
+// each trimmed to the byte columns it uses; a blank label has no regions
+func bitmapRegions(data []byte, widthBytes, height int) []bitmapRegion {
+	if widthBytes <= 0 || len(data) < widthBytes*height {
+		return nil
+	}
+
+	// Rows of blank space worth a separate BITMAP command
+	minGap := bitmapHeaderCost/widthBytes + 1
+
+	var regions []bitmapRegion
+	start, gap := -1, 0
+	for row := 0; row <= height; row++ {
+		if row < height && !blankRow(data, widthBytes, row) {
+			if start < 0 {
+				start = row
+			}
+			gap = 0
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+		gap++
+		if row == height || gap >= minGap {
+			end := row - gap + 1
+			regions = append(regions, trimColumns(data, widthBytes, start, end))
+			start, gap = -1, 0
+		}
+	}
+	return regions
+}
+
+// trimColumns returns the rows [top, bottom) narrowed to the byte columns
+// that contain black dots
+func trimColumns(data []byte, widthBytes, top, bottom int) bitmapRegion {
+	left, right := widthBytes, 0
+	for row := top; row < bottom; row++ {
+		line := data[row*widthBytes : (row+1)*widthBytes]
+		for col, b := range line {
+			if b == blankByte {
+				continue
+			}
+			if col < left {
+				left = col
+			}
+			if col+1 > right {
+				right = col + 1
+			}
+		}
+	}
+	return bitmapRegion{x: left, y: top, width: right - left, height: bottom - top}
+}
+
+// regionData copies a region out of the label bitmap
+func regionData(data []byte, widthBytes int, r bitmapRegion) []byte {
+	out := make([]byte, 0, r.width*r.height)
+	for row := r.y; row < r.y+r.height; row++ {
+		offset := row*widthBytes + r.x
+		out = append(out, data[offset:offset+r.width]...)
+	}
+	return out
+}
@@ -0,0 +1,206 @@
+package tspl
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nelko-print/internal/imaging"
+)
+
+// labelImage returns a white bitmap for a label size with the rectangles in
+// black, given as x0, y0, x1, y1 in dots, drawn black
+func labelImage(size LabelSize, black ...[4]int) *imaging.Bitmap {
+	bm := imaging.NewBitmap(image.Rect(0, 0, size.PixelW, size.PixelH))
+	for _, r := range black {
+		for y := r[1]; y < r[3]; y++ {
+			for x := r[0]; x < r[2]; x++ {
+				bm.SetBlack(x, y, true)
+			}
+		}
+	}
+	return bm
+}
+
+// labelBitmap returns the BITMAP data expected for labelImage: blank bytes
+// with the dots in black cleared
+func labelBitmap(size LabelSize, black ...[4]int) []byte {
+	widthBytes := (size.PixelW + 7) / 8
+	data := bytes.Repeat([]byte{blankByte}, widthBytes*size.PixelH)
+	for _, r := range black {
+		for y := r[1]; y < r[3]; y++ {
+			for x := r[0]; x < r[2]; x++ {
+				data[y*widthBytes+x/8] &^= 0x80 >> (x % 8)
+			}
+		}
+	}
+	return data
+}
+
+// replayJob draws the BITMAP commands of a one-label job onto a blank label
+// and returns it, with how many BITMAP commands there were
+func replayJob(t *testing.T, job []byte, size LabelSize, model Model) ([]byte, int) {
+	t.Helper()
+	widthBytes := (size.PixelW + 7) / 8
+	x0 := max(0, (model.WidthDots-size.PixelW)/2)
+	label := bytes.Repeat([]byte{blankByte}, widthBytes*size.PixelH)
+
+	commands := 0
+	for {
+		i := bytes.Index(job, []byte("BITMAP "))
+		if i < 0 {
+			return label, commands
+		}
+		job = job[i:]
+		var x, y, w, h, mode int
+		if _, err := fmt.Sscanf(string(job), "BITMAP %d,%d,%d,%d,%d,", &x, &y, &w, &h, &mode); err != nil {
+			t.Fatalf("bad BITMAP header: %v", err)
+		}
+		job = job[bytes.IndexByte(job, ',')+1:]
+		for n := 0; n < 4; n++ {
+			job = job[bytes.IndexByte(job, ',')+1:]
+		}
+
+		var data []byte
+		switch mode {
+		case 1:
+			data, job = job[:w*h], job[w*h:]
+		case bitmapModeRLE:
+			// Decode until the region is complete; the data may contain CRLF
+			end := 0
+			for len(data) < w*h {
+				end += packedLength(job[end:])
+				data = unpackBits(t, job[:end])
+			}
+			job = job[end:]
+		default:
+			t.Fatalf("unexpected BITMAP mode %d", mode)
+		}
+		if len(data) != w*h {
+			t.Fatalf("BITMAP %d,%d has %d bytes of data, want %d", x, y, len(data), w*h)
+		}
+		if (x-x0)%8 != 0 {
+			t.Fatalf("BITMAP x %d is not on a byte column", x)
+		}
+
+		col := (x - x0) / 8
+		for row := 0; row < h; row++ {
+			copy(label[(y+row)*widthBytes+col:], data[row*w:(row+1)*w])
+		}
+		commands++
+	}
+}
+
+// packedLength returns the length of the first PackBits block in packed
+func packedLength(packed []byte) int {
+	if n := int(packed[0]); n < 128 {
+		return n + 2
+	}
+	return 2
+}
+
+func TestCroppedJobMatchesBitmap(t *testing.T) {
+	wide := newLabelSize("48x30mm", 48, 30, 8, 384)
+	narrow, err := ModelP21.CustomLabelSize(LabelSize{Width: 12, Height: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		size     LabelSize
+		black    [][4]int
+		commands int // BITMAP commands expected
+	}{
+		{"blank", Label14x40, nil, 0},
+		{"all black", Label14x40, [][4]int{{0, 0, Label14x40.PixelW, Label14x40.PixelH}}, 1},
+		{"disjoint bands", Label14x40, [][4]int{{10, 20, 40, 50}, {50, 200, 90, 260}}, 2},
+		{"disjoint columns", wide, [][4]int{{0, 10, 16, 40}, {300, 10, 384, 40}}, 1},
+		{"first byte column", Label14x40, [][4]int{{0, 5, 1, 280}}, 1},
+		{"last byte column", Label14x40, [][4]int{{Label14x40.PixelW - 1, 5, Label14x40.PixelW, 280}}, 1},
+		{"odd width", narrow, [][4]int{{0, 0, narrow.PixelW, 3}, {narrow.PixelW - 3, 100, narrow.PixelW, 120}}, 2},
+		{"odd width all black", narrow, [][4]int{{0, 0, narrow.PixelW, narrow.PixelH}}, 1},
+		{"single dot", Label14x40, [][4]int{{37, 141, 38, 142}}, 1},
+		{"close bands merge", Label14x40, [][4]int{{8, 10, 16, 12}, {8, 13, 16, 15}}, 1},
+	}
+	if narrow.PixelW%8 == 0 {
+		t.Fatalf("custom 12 mm label is %d dots wide; the odd width cases need a width that is not a multiple of 8", narrow.PixelW)
+	}
+
+	for _, tt := range tests {
+		for _, compress := range []bool{false, true} {
+			want := labelBitmap(tt.size, tt.black...)
+			settings := JobSettings{Model: ModelP21, Size: tt.size, Density: 8, Compress: compress}
+			if tt.size.PixelW > ModelP21.WidthDots {
+				settings.Model = ModelGeneric203
+			}
+			job := NewJob(settings).AddLabel(labelImage(tt.size, tt.black...), 1).Bytes()
+
+			got, commands := replayJob(t, job, tt.size, settings.Model)
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s (compress %v): cropped label differs from the full bitmap", tt.name, compress)
+			}
+			if commands != tt.commands {
+				t.Errorf("%s (compress %v): %d BITMAP commands, want %d", tt.name, compress, commands, tt.commands)
+			}
+		}
+	}
+}
+
+func TestAddLabelFitsBitmap(t *testing.T) {
+	// An odd width leaves padding bits at the end of each row
+	size, err := ModelP21.CustomLabelSize(LabelSize{Width: 12, Height: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		image *imaging.Bitmap
+		want  []byte
+	}{
+		{"exact", labelImage(size, [4]int{2, 3, 10, 5}), labelBitmap(size, [4]int{2, 3, 10, 5})},
+		{"smaller", labelImage(LabelSize{PixelW: 10, PixelH: 10}, [4]int{0, 0, 10, 10}), labelBitmap(size, [4]int{0, 0, 10, 10})},
+		{"larger", labelImage(LabelSize{PixelW: 200, PixelH: 400}, [4]int{0, 0, 200, 400}),
+			labelBitmap(size, [4]int{0, 0, size.PixelW, size.PixelH})},
+	}
+	for _, tt := range tests {
+		job := NewJob(JobSettings{Model: ModelP21, Size: size, Density: 8}).AddLabel(tt.image, 1).Bytes()
+		if got, _ := replayJob(t, job, size, ModelP21); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: printed label differs from the bitmap", tt.name)
+		}
+	}
+}
+
+// goldenLabel is the label in testdata/p21_12x40.tspl: a border that reaches
+// every edge, so nothing is cropped, and a few shapes inside it
+var goldenLabel = [][4]int{
+	{0, 0, 96, 2}, {0, 282, 96, 284}, {0, 0, 2, 284}, {94, 0, 96, 284},
+	{10, 20, 40, 50}, {50, 200, 90, 260}, {37, 141, 38, 142},
+}
+
+// The fixture is the output of the original full-bitmap BuildPrintJob for
+// goldenLabel on a 12x40 mm label at density 8, 2 copies
+func TestBuildPrintJobGolden(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join("testdata", "p21_12x40.tspl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup := want[:bytes.Index(want, []byte("BITMAP "))]
+
+	got := BuildPrintJob(Label12x40, 8, labelImage(Label12x40, goldenLabel...), 2)
+	if !bytes.Equal(got, want) {
+		t.Errorf("job differs from testdata/p21_12x40.tspl, starting %q", got[:min(len(got), len(setup)+24)])
+	}
+
+	// A label with blank margins is cropped, but keeps the same setup and
+	// print commands around its bitmaps
+	got = BuildPrintJob(Label12x40, 8, labelImage(Label12x40, [4]int{10, 20, 40, 50}), 2)
+	if !bytes.HasPrefix(got, setup) {
+		t.Errorf("cropped job starts %q, want %q", got[:min(len(got), len(setup))], setup)
+	}
+	if print := want[bytes.LastIndex(want, []byte("\r\nPRINT ")):]; !bytes.HasSuffix(got, print) {
+		t.Errorf("cropped job does not end with %q", print)
+	}
+}
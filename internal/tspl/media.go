@@ -0,0 +1,100 @@
+package tspl
+
+// MediaType selects how the printer finds the start of each label
+type MediaType int
+
+const (
+	MediaGap        MediaType = iota // die-cut labels separated by a gap
+	MediaBlackMark                   // stock with printed black marks on the back
+	MediaContinuous                  // continuous stock, no sensing
+)
+
+// MediaTypeNames are the display names of the media types, indexed by MediaType
+var MediaTypeNames = []string{"Gap", "Black mark", "Continuous"}
+
+func (t MediaType) String() string {
+	if t < 0 || int(t) >= len(MediaTypeNames) {
+		return MediaTypeNames[MediaGap]
+	}
+	return MediaTypeNames[t]
+}
+
+// Media describes the loaded label stock and print position adjustments
+// A nil length is not set: the gap and offset fall back to the label size's
+// and model's defaults, and the position commands are not sent, leaving the
+// printer's own setting; 0 is sent as 0
+// The zero value is gap media using the defaults
+type Media struct {
+	Type      MediaType
+	Gap       *float64 // mm gap or black mark height
+	GapOffset *float64 // mm
+
+	Reverse bool // rotate the print 180° relative to the model's normal direction
+	Mirror  bool // mirror the print horizontally
+
+	ReferenceX *float64 // mm, origin of the label image
+	ReferenceY *float64 // mm
+	ShiftY     *float64 // mm, moves the print down (positive) or up (negative)
+	FeedOffset *float64 // mm, extra feed after each label for tearing or peeling
+}
+
+// apply writes the media setup commands for a label size on a model
+func (m Media) apply(cmd *Command, model Model, size LabelSize) {
+	gap := model.DefaultGap
+	switch {
+	case m.Gap != nil:
+		gap = *m.Gap
+	case size.Gap != nil:
+		gap = *size.Gap
+	}
+	offset := size.Offset
+	if m.GapOffset != nil {
+		offset = *m.GapOffset
+	}
+
+	switch m.Type {
+	case MediaBlackMark:
+		cmd.BLine(gap, offset)
+	case MediaContinuous:
+		cmd.Gap(0, 0)
+	default:
+		cmd.Gap(gap, offset)
+	}
+
+	dir := model.Direction
+	if m.Reverse {
+		dir = 1 - dir
+	}
+	mirror := 0
+	if m.Mirror {
+		mirror = 1
+	}
+	cmd.Direction(dir, mirror)
+
+	// Only send adjustments that were set so the default job stays unchanged
+	if m.ReferenceX != nil || m.ReferenceY != nil {
+		cmd.Reference(mmToDotsSigned(value(m.ReferenceX), model.DotsPerMM), mmToDotsSigned(value(m.ReferenceY), model.DotsPerMM))
+	}
+	if m.ShiftY != nil {
+		cmd.Shift(mmToDotsSigned(*m.ShiftY, model.DotsPerMM))
+	}
+	if m.FeedOffset != nil {
+		cmd.Offset(*m.FeedOffset)
+	}
+}
+
+// value returns an optional length, 0 if it is not set
+func value(mm *float64) float64 {
+	if mm == nil {
+		return 0
+	}
+	return *mm
+}
+
+// mmToDotsSigned converts mm to dots rounding toward zero, keeping the sign
+func mmToDotsSigned(mm, dotsPerMM float64) int {
+	if mm < 0 {
+		return -MMToDots(-mm, dotsPerMM)
+	}
+	return MMToDots(mm, dotsPerMM)
+}
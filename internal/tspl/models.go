@@ -0,0 +1,189 @@
+package tspl
+
+import (
+	"fmt"
+	"math"
+)
+
+// StatusProtocol identifies how a printer reports its state
+type StatusProtocol int
+
+const (
+	StatusNone StatusProtocol = iota // no status reporting
+	StatusESC                        // TSPL2 "ESC ! ?" status byte
+)
+
+// Feature is an optional command a model understands
+type Feature uint
+
+const (
+	FeatureBattery     Feature = 1 << iota // battery level query
+	FeatureConfig                          // CONFIG? query
+	FeatureCancelPause                     // "ESC ! o" resumes from pause
+	FeatureGapDetect                       // GAPDETECT / BLINEDETECT media calibration
+	FeatureSelfTest                        // SELFTEST page
+	FeatureHome                            // HOME and FORMFEED
+	FeatureInitialize                      // INITIALPRINTER factory reset
+	FeatureTear                            // SET TEAR ON/OFF
+	FeatureSpeed                           // SPEED
+	FeatureBitmapRLE                       // BITMAP mode 3 with run-length encoded data
+)
+
+// Model describes a TSPL-speaking label printer
+type Model struct {
+	Name        string
+	DotsPerMM   float64 // print resolution along the feed direction
+	WidthDots   int     // printable width of the print head, a multiple of 8
+	MaxWidthMM  float64 // widest label the media guide holds
+	MaxLengthMM float64 // longest label the printer accepts
+	DefaultGap  float64 // gap between labels in mm
+	Direction   int     // DIRECTION value that prints labels the right way up
+
+	Status       StatusProtocol
+	BatteryQuery string // command that returns the battery level, "" if unsupported
+	Features     Feature
+
+	Speeds []float64 // print speeds in inches per second accepted by SPEED
+
+	Sizes       []LabelSize // label sizes sold for this model
+	DefaultSize LabelSize
+}
+
+// DPI returns the print resolution in dots per inch
+func (m Model) DPI() float64 {
+	return m.DotsPerMM * 25.4
+}
+
+// Supports reports whether the model understands an optional command
+func (m Model) Supports(f Feature) bool {
+	return m.Features&f != 0
+}
+
+// MMToDots converts a length in mm to whole printer dots
+func MMToDots(mm, dotsPerMM float64) int {
+	return int(math.Floor(mm * dotsPerMM))
+}
+
+// DotsToMM converts a number of printer dots to mm
+func DotsToMM(dots int, dotsPerMM float64) float64 {
+	return float64(dots) / dotsPerMM
+}
+
+// newLabelSize computes the bitmap size of a label
+// The bitmap always spans the full print head; the length follows the label
+func newLabelSize(name string, width, height, dotsPerMM float64, widthDots int) LabelSize {
+	return LabelSize{
+		Name:   name,
+		Width:  width,
+		Height: height,
+		PixelW: widthDots,
+		PixelH: MMToDots(height, dotsPerMM),
+	}
+}
+
+// LabelSize returns a width x height mm label with its dot dimensions for this model
+func (m Model) LabelSize(name string, width, height float64) LabelSize {
+	return newLabelSize(name, width, height, m.DotsPerMM, m.WidthDots)
+}
+
+// CustomLabelSize checks a user-defined label against the model's limits and
+// computes its dot dimensions from Width, Height, Gap, Offset and CornerRadius
+// The bitmap is as wide as the label, or the print head if the label is wider
+// A nil Gap uses the model's default; 0 is for continuous or gapless stock
+func (m Model) CustomLabelSize(spec LabelSize) (LabelSize, error) {
+	if MMToDots(spec.Width, m.DotsPerMM) < 8 {
+		return LabelSize{}, fmt.Errorf("label width %.1f mm is narrower than 8 dots", spec.Width)
+	}
+	if spec.Width > m.MaxWidthMM {
+		return LabelSize{}, fmt.Errorf("label width %.1f mm does not fit the %s's %.1f mm media guide",
+			spec.Width, m.Name, m.MaxWidthMM)
+	}
+	if spec.Height <= 0 || spec.Height > m.MaxLengthMM {
+		return LabelSize{}, fmt.Errorf("label length %.1f mm must be between 0 and %.0f mm", spec.Height, m.MaxLengthMM)
+	}
+	if MMToDots(spec.Height, m.DotsPerMM) < 8 {
+		return LabelSize{}, fmt.Errorf("label length %.1f mm is too short to print", spec.Height)
+	}
+	if spec.Gap != nil && (*spec.Gap < 0 || *spec.Gap > spec.Height) {
+		return LabelSize{}, fmt.Errorf("gap %.1f mm must be between 0 and the label length", *spec.Gap)
+	}
+	if math.Abs(spec.Offset) > spec.Height {
+		return LabelSize{}, fmt.Errorf("gap offset %.1f mm is larger than the label", spec.Offset)
+	}
+	if spec.CornerRadius < 0 || spec.CornerRadius > math.Min(spec.Width, spec.Height)/2 {
+		return LabelSize{}, fmt.Errorf("corner radius %.1f mm does not fit the label", spec.CornerRadius)
+	}
+
+	name := spec.Name
+	if name == "" {
+		name = fmt.Sprintf("%gx%gmm", spec.Width, spec.Height)
+	}
+
+	size := m.LabelSize(name, spec.Width, spec.Height)
+	size.PixelW = min(MMToDots(spec.Width, m.DotsPerMM), m.WidthDots)
+	size.Gap = spec.Gap
+	size.Offset = spec.Offset
+	size.CornerRadius = spec.CornerRadius
+	size.Custom = true
+	return size, nil
+}
+
+// P21 print head geometry
+const (
+	p21DotsPerMM = 7.1 // 284 dots on a 40 mm label
+	p21WidthDots = 96
+)
+
+// Known printer models
+var (
+	// ModelP21 is the Nelko P21 (96 dot head, 12-15 mm labels)
+	ModelP21 = Model{
+		Name:         "Nelko P21",
+		DotsPerMM:    p21DotsPerMM,
+		WidthDots:    p21WidthDots,
+		MaxWidthMM:   16,
+		MaxLengthMM:  100,
+		DefaultGap:   5.0,
+		Direction:    0,
+		Status:       StatusESC,
+		BatteryQuery: "BATTERY?",
+		Features: FeatureBattery | FeatureConfig | FeatureCancelPause |
+			FeatureGapDetect | FeatureSelfTest | FeatureHome | FeatureTear,
+		Sizes:       []LabelSize{Label12x40, Label14x40, Label14x50, Label14x75, Label15x30},
+		DefaultSize: Label14x40,
+	}
+
+	// ModelGeneric203 is a plain 203 dpi TSPL desktop printer with a 2 inch head
+	ModelGeneric203 = Model{
+		Name:        "Generic TSPL 203 dpi",
+		DotsPerMM:   8,
+		WidthDots:   384,
+		MaxWidthMM:  60,
+		MaxLengthMM: 300,
+		DefaultGap:  2.0,
+		Direction:   0,
+		Status:      StatusESC,
+		Features: FeatureConfig | FeatureGapDetect | FeatureSelfTest | FeatureHome |
+			FeatureInitialize | FeatureTear | FeatureSpeed,
+		Speeds: []float64{2, 3, 4, 5},
+		Sizes: []LabelSize{
+			newLabelSize("50x25mm", 50.0, 25.0, 8, 384),
+			newLabelSize("50x30mm", 50.0, 30.0, 8, 384),
+			newLabelSize("50x50mm", 50.0, 50.0, 8, 384),
+		},
+		DefaultSize: newLabelSize("50x30mm", 50.0, 30.0, 8, 384),
+	}
+)
+
+// AllModels lists the printer models the app can drive
+var AllModels = []Model{ModelP21, ModelGeneric203}
+
+// ModelByName returns the model with the given name, or the P21 if unknown
+func ModelByName(name string) Model {
+	for _, m := range AllModels {
+		if m.Name == name {
+			return m
+		}
+	}
+	return ModelP21
+}
@@ -2,7 +2,10 @@ package tspl
 
 import (
 	"fmt"
+	"image"
 	"strings"
+
+	"nelko-print/internal/imaging"
 )
 
 // LabelSize represents a supported label dimension
@@ -10,19 +13,32 @@ type LabelSize struct {
 	Name   string
 	Width  float64 // mm
 	Height float64 // mm
-	PixelW int     // pixels (96 for P21)
+	PixelW int     // pixels across the label, at most the model's head width
 	PixelH int     // pixels
+
+	Gap *float64 // mm between labels, nil uses the model's default
+
+	Offset       float64 // mm gap offset
+	CornerRadius float64 // mm, rounded corners of die-cut labels (shown in the preview)
+	Custom       bool    // user-defined rather than built in
+}
+
+// MM returns a pointer to a length in mm, for settings that fall back to a
+// default when nil
+func MM(mm float64) *float64 {
+	return &mm
 }
 
 // Common P21 label sizes
 var (
-	Label12x40 = LabelSize{"12x40mm", 12.0, 40.0, 96, 284}
-	Label14x40 = LabelSize{"14x40mm", 14.0, 40.0, 96, 284}
-	Label14x50 = LabelSize{"14x50mm", 14.0, 50.0, 96, 355}
-	Label14x75 = LabelSize{"14x75mm", 14.0, 75.0, 96, 532}
-	Label15x30 = LabelSize{"15x30mm", 15.0, 30.0, 96, 213}
+	Label12x40 = newLabelSize("12x40mm", 12.0, 40.0, p21DotsPerMM, p21WidthDots)
+	Label14x40 = newLabelSize("14x40mm", 14.0, 40.0, p21DotsPerMM, p21WidthDots)
+	Label14x50 = newLabelSize("14x50mm", 14.0, 50.0, p21DotsPerMM, p21WidthDots)
+	Label14x75 = newLabelSize("14x75mm", 14.0, 75.0, p21DotsPerMM, p21WidthDots)
+	Label15x30 = newLabelSize("15x30mm", 15.0, 30.0, p21DotsPerMM, p21WidthDots)
 )
 
+// AllSizes lists the P21 label sizes (see Model.Sizes for other printers)
 var AllSizes = []LabelSize{Label12x40, Label14x40, Label14x50, Label14x75, Label15x30}
 
 // Command builds TSPL2 commands
@@ -46,6 +62,30 @@ func (c *Command) Gap(gap, offset float64) *Command {
 	return c
 }
 
+// BLine sets the black mark height and offset for black-mark media
+func (c *Command) BLine(height, offset float64) *Command {
+	fmt.Fprintf(&c.buf, "BLINE %.1f mm,%.1f mm\r\n", height, offset)
+	return c
+}
+
+// Reference sets the origin of the label in dots
+func (c *Command) Reference(x, y int) *Command {
+	fmt.Fprintf(&c.buf, "REFERENCE %d,%d\r\n", x, y)
+	return c
+}
+
+// Shift moves the print position vertically by n dots (may be negative)
+func (c *Command) Shift(n int) *Command {
+	fmt.Fprintf(&c.buf, "SHIFT %d\r\n", n)
+	return c
+}
+
+// Offset sets the extra feed after each label in mm (tear/peel position)
+func (c *Command) Offset(mm float64) *Command {
+	fmt.Fprintf(&c.buf, "OFFSET %.1f mm\r\n", mm)
+	return c
+}
+
 // Direction sets print direction (0 or 1)
 func (c *Command) Direction(dir, mirror int) *Command {
 	fmt.Fprintf(&c.buf, "DIRECTION %d,%d\r\n", dir, mirror)
@@ -76,7 +116,14 @@ func (c *Command) CLS() *Command {
 // height: height in dots
 // data: raw 1-bit bitmap data
 func (c *Command) Bitmap(x, y, widthBytes, height int, data []byte) *Command {
-	fmt.Fprintf(&c.buf, "BITMAP %d,%d,%d,%d,1,", x, y, widthBytes, height)
+	return c.BitmapMode(x, y, widthBytes, height, 1, data)
+}
+
+// BitmapMode adds a bitmap with an explicit BITMAP mode
+// Modes 0-2 take raw data (overwrite, OR, XOR); mode 3 takes run-length
+// encoded data on printers that accept it
+func (c *Command) BitmapMode(x, y, widthBytes, height, mode int, data []byte) *Command {
+	fmt.Fprintf(&c.buf, "BITMAP %d,%d,%d,%d,%d,", x, y, widthBytes, height, mode)
 	c.buf.Write(data)
 	c.buf.WriteString("\r\n")
 	return c
@@ -88,6 +135,58 @@ func (c *Command) Print(copies int) *Command {
 	return c
 }
 
+// GapDetect measures the label and gap length to calibrate the gap sensor
+func (c *Command) GapDetect() *Command {
+	c.buf.WriteString("GAPDETECT\r\n")
+	return c
+}
+
+// BLineDetect calibrates the black mark sensor
+func (c *Command) BLineDetect() *Command {
+	c.buf.WriteString("BLINEDETECT\r\n")
+	return c
+}
+
+// SelfTest prints the printer's self-test page
+func (c *Command) SelfTest() *Command {
+	c.buf.WriteString("SELFTEST\r\n")
+	return c
+}
+
+// Home feeds to the start of the next label
+func (c *Command) Home() *Command {
+	c.buf.WriteString("HOME\r\n")
+	return c
+}
+
+// FormFeed feeds one label
+func (c *Command) FormFeed() *Command {
+	c.buf.WriteString("FORMFEED\r\n")
+	return c
+}
+
+// InitialPrinter restores the printer's factory settings
+func (c *Command) InitialPrinter() *Command {
+	c.buf.WriteString("INITIALPRINTER\r\n")
+	return c
+}
+
+// SetTear enables or disables feeding labels to the tear bar after printing
+func (c *Command) SetTear(on bool) *Command {
+	if on {
+		c.buf.WriteString("SET TEAR ON\r\n")
+	} else {
+		c.buf.WriteString("SET TEAR OFF\r\n")
+	}
+	return c
+}
+
+// Speed sets the print speed in inches per second
+func (c *Command) Speed(ips float64) *Command {
+	fmt.Fprintf(&c.buf, "SPEED %g\r\n", ips)
+	return c
+}
+
 // Bytes returns the raw command bytes to send to printer
 func (c *Command) Bytes() []byte {
 	return []byte(c.buf.String())
@@ -98,15 +197,122 @@ func (c *Command) String() string {
 	return c.buf.String()
 }
 
-// BuildPrintJob creates a complete print job for the P21
-func BuildPrintJob(size LabelSize, density int, bitmap []byte, copies int) []byte {
+// JobSettings holds the printer setup shared by every label in a job
+type JobSettings struct {
+	Model   Model
+	Size    LabelSize
+	Media   Media
+	Density int
+	// Compress sends run-length encoded bitmaps (BITMAP mode 3) even if the
+	// model is not known to accept them; see Printer.PrintJob for the fallback
+	Compress bool
+}
+
+// compress reports whether bitmaps are sent run-length encoded
+func (s JobSettings) compress() bool {
+	return s.Compress || s.Model.Supports(FeatureBitmapRLE)
+}
+
+// jobLabel is a label added to a job, as BITMAP data
+type jobLabel struct {
+	data   []byte
+	copies int
+}
+
+// Job is a print stream holding any number of labels that share one setup,
+// so a whole batch is sent to the printer in a single write
+type Job struct {
+	settings JobSettings
+	labels   []jobLabel
+}
+
+// NewJob starts a job; the label size, media and density setup is written
+// once, ahead of the labels
+func NewJob(settings JobSettings) *Job {
+	return &Job{settings: settings}
+}
+
+// AddLabel appends one label image, printed copies times
+// The bitmap should be PixelW x PixelH dots; it is cropped or padded with
+// white to that size
+func (j *Job) AddLabel(bitmap *imaging.Bitmap, copies int) *Job {
+	size := j.settings.Size
+	j.labels = append(j.labels, jobLabel{data: bitmapData(bitmap, size.PixelW, size.PixelH), copies: copies})
+	return j
+}
+
+// bitmapData converts a bitmap to BITMAP data, in which a set bit is white,
+// padding or cropping it to width x height dots
+func bitmapData(bm *imaging.Bitmap, width, height int) []byte {
+	if bm.Bounds() != image.Rect(0, 0, width, height) {
+		label := imaging.NewBitmap(image.Rect(0, 0, width, height))
+		label.Blit(image.Point{}, bm, bm.Bounds(), imaging.OpCopy)
+		bm = label
+	}
+
+	data := make([]byte, len(bm.Pix))
+	for i, b := range bm.Pix {
+		data[i] = ^b
+	}
+	// The unused bits at the end of each row must be white too
+	if width%8 != 0 {
+		for row := bm.Stride - 1; row < len(data); row += bm.Stride {
+			data[row] |= 0xFF >> uint(width%8)
+		}
+	}
+	return data
+}
+
+// Labels returns the number of different labels added to the job
+func (j *Job) Labels() int {
+	return len(j.labels)
+}
+
+// Compressed reports whether the job sends run-length encoded bitmaps
+func (j *Job) Compressed() bool {
+	return j.settings.compress()
+}
+
+// Uncompressed returns the same job sending plain bitmaps
+func (j *Job) Uncompressed() *Job {
+	plain := *j
+	plain.settings.Compress = false
+	plain.settings.Model.Features &^= FeatureBitmapRLE
+	return &plain
+}
+
+// Bytes returns the job's command stream
+// Blank margins of each label are not sent
+func (j *Job) Bytes() []byte {
 	cmd := New()
-	cmd.Size(size.Width, size.Height).
-		Gap(5.0, 0).
-		Direction(0, 0).
-		Density(density).
-		CLS().
-		Bitmap(0, 0, size.PixelW/8, size.PixelH, bitmap).
-		Print(copies)
+	size := j.settings.Size
+	cmd.Size(size.Width, size.Height)
+	j.settings.Media.apply(cmd, j.settings.Model, size)
+	cmd.Density(j.settings.Density)
+
+	widthBytes := (size.PixelW + 7) / 8
+	// The media guide centres labels narrower than the head under it
+	x0 := max(0, (j.settings.Model.WidthDots-size.PixelW)/2)
+	compress := j.settings.compress()
+
+	for _, l := range j.labels {
+		// CLS leaves the label white, so only the parts with black dots are
+		// sent, each at its own offset; BITMAP mode 1 ORs them onto the label
+		cmd.CLS()
+		for _, r := range bitmapRegions(l.data, widthBytes, size.PixelH) {
+			writeBitmap(cmd, compress, x0+r.x*8, r.y, r.width, r.height, regionData(l.data, widthBytes, r))
+		}
+		cmd.Print(l.copies)
+	}
 	return cmd.Bytes()
 }
+
+// BuildJob creates a complete print job for the settings' printer model
+func BuildJob(settings JobSettings, bitmap *imaging.Bitmap, copies int) []byte {
+	return NewJob(settings).AddLabel(bitmap, copies).Bytes()
+}
+
+// BuildPrintJob creates a complete print job for the P21
+func BuildPrintJob(size LabelSize, density int, bitmap *imaging.Bitmap, copies int) []byte {
+	return BuildJob(JobSettings{Model: ModelP21, Size: size, Density: density}, bitmap, copies)
+}